@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"strings"
@@ -68,7 +70,65 @@ func main() {
 	if err != nil {
 		logrus.Fatalf("error connecting to s3: %s", err.Error())
 	}
-	services := service.NewService(repo, s3, viper.GetString("s3.bucket"))
+	signingKeys, err := NewSigningKeys()
+	if err != nil {
+		logrus.Fatalf("error loading signing keys: %s", err.Error())
+	}
+	notificationTemplates, err := service.NewNotificationTemplates()
+	if err != nil {
+		logrus.Fatalf("error loading notification templates: %s", err.Error())
+	}
+	mailer := NewMailer()
+	authLinks := service.AuthLinks{
+		VerifyEmailURL:        viper.GetString("mail.verifyEmailUrl"),
+		ResetPasswordURL:      viper.GetString("mail.resetPasswordUrl"),
+		ConfirmEmailChangeURL: viper.GetString("mail.confirmEmailChangeUrl"),
+		ReauthURL:             viper.GetString("mail.reauthUrl"),
+	}
+	twoFactorPolicy := service.TwoFactorPolicy{
+		Issuer:        viper.GetString("auth.twoFactor.issuer"),
+		RequiredRoles: viper.GetStringSlice("auth.twoFactor.requiredRoles"),
+	}
+	var rateLimitGroups map[string]service.RateLimitGroup
+	if err := viper.UnmarshalKey("rateLimits.groups", &rateLimitGroups); err != nil {
+		logrus.Fatalf("error loading rate limits: %s", err.Error())
+	}
+	loginLockout := service.LoginLockoutPolicy{
+		Threshold:  viper.GetInt("auth.lockout.threshold"),
+		LockFor:    viper.GetDuration("auth.lockout.lockFor"),
+		MaxLockFor: viper.GetDuration("auth.lockout.maxLockFor"),
+		ResetAfter: viper.GetDuration("auth.lockout.resetAfter"),
+	}
+	payments, err := NewPaymentProvider()
+	if err != nil {
+		logrus.Fatalf("error initializing payment provider: %s", err.Error())
+	}
+	oauth, err := NewOAuthConfig()
+	if err != nil {
+		logrus.Fatalf("error loading oauth providers: %s", err.Error())
+	}
+	services := service.NewService(repo, service.Deps{
+		SigningKeys:             signingKeys,
+		Mailer:                  mailer,
+		AuthLinks:               authLinks,
+		TwoFactor:               twoFactorPolicy,
+		LoginLockout:            loginLockout,
+		OAuth:                   oauth,
+		RateLimitStore:          NewRateLimitStore(repo),
+		RateLimitGroups:         rateLimitGroups,
+		GuestCartTTL:            viper.GetDuration("guestCarts.ttl"),
+		S3:                      s3,
+		Bucket:                  viper.GetString("s3.bucket"),
+		Payments:                payments,
+		PaymentReturnURL:        viper.GetString("payments.returnUrl"),
+		PaymentTTL:              viper.GetDuration("payments.deadline"),
+		ReservationTTL:          viper.GetDuration("checkout.reservationTtl"),
+		Delivery:                NewDeliveryProvider(),
+		IdempotencyTTL:          viper.GetDuration("idempotency.ttl"),
+		NotificationTemplates:   notificationTemplates,
+		NotificationOrdersURL:   viper.GetString("notifications.ordersUrl"),
+		NotificationMaxAttempts: viper.GetInt("notifications.maxAttempts"),
+	})
 	if err := services.CreateAdmin(viper.GetString("admin.name"), viper.GetString("admin.email"), viper.GetString("admin.password")); err != nil {
 		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" && strings.Contains(pgErr.Message, "users_email_key") {
 			logrus.Info("Admin already exists")
@@ -76,10 +136,27 @@ func main() {
 			logrus.Fatalf("error creating admin: %s", err.Error())
 		}
 	}
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go service.RunWorker(workersCtx, "unpaid orders canceller", viper.GetDuration("payments.cancelInterval"), services.Payments.CancelUnpaidOrders)
+	go service.RunWorker(workersCtx, "refunds sender", viper.GetDuration("payments.refundInterval"), services.Payments.SendPendingRefunds)
+	go service.RunWorker(workersCtx, "stock reservations sweeper", viper.GetDuration("checkout.sweepInterval"), services.Orders.ReleaseExpiredReservations)
+	go service.RunWorker(workersCtx, "delivery tracking poller", viper.GetDuration("delivery.pollInterval"), services.Delivery.SyncShipments)
+	go service.RunWorker(workersCtx, "idempotency keys cleaner", viper.GetDuration("idempotency.cleanupInterval"), services.Idempotency.DeleteExpiredKeys)
+	go service.RunWorker(workersCtx, "refresh tokens cleaner", viper.GetDuration("auth.cleanupInterval"), services.Auth.DeleteExpiredRefreshTokens)
+	go service.RunWorker(workersCtx, "user tokens cleaner", viper.GetDuration("auth.cleanupInterval"), services.Auth.DeleteExpiredUserTokens)
+	go service.RunWorker(workersCtx, "failed sign ins cleaner", viper.GetDuration("auth.cleanupInterval"), services.Auth.DeleteStaleLoginFailures)
+	go service.RunWorker(workersCtx, "rate limits cleaner", viper.GetDuration("rateLimits.cleanupInterval"), services.RateLimits.DeleteExpiredRateLimits)
+	go service.RunWorker(workersCtx, "guest carts cleaner", viper.GetDuration("guestCarts.cleanupInterval"), services.GuestCarts.DeleteExpiredGuestCarts)
+	go service.RunWorker(workersCtx, "notifications sender", viper.GetDuration("notifications.interval"), services.Notifications.SendPendingNotifications)
 	endp := endpoint.NewEndpoint(services)
+	router := endp.InitRoutes()
+	if err := router.SetTrustedProxies(viper.GetStringSlice("trustedProxies")); err != nil {
+		logrus.Fatalf("error setting trusted proxies: %s", err.Error())
+	}
 	server := &backend.Server{}
 	go func() {
-		if err := server.Run(viper.GetString("port"), endp.InitRoutes()); err != nil {
+		if err := server.Run(viper.GetString("port"), router); err != nil {
 			logrus.Fatalf("error running http server: %s", err.Error())
 		}
 	}()
@@ -87,12 +164,175 @@ func main() {
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
 	logrus.Print("Shutting down server...")
+	stopWorkers()
 	if err := server.Shutdown(context.Background()); err != nil {
 		logrus.Errorf("error shutting down server: %s", err.Error())
 	}
 
 }
 
+// devMode enables providers that must never run in production, such as the
+// fake payment provider.
+func devMode() bool {
+	return os.Getenv("DEV_MODE") == "true"
+}
+
+func NewPaymentProvider() (service.PaymentProvider, error) {
+	switch provider := viper.GetString("payments.provider"); provider {
+	case "yookassa":
+		return service.NewYooKassaProvider(service.YooKassaConfig{
+			URL:             viper.GetString("payments.yookassa.url"),
+			ShopID:          viper.GetString("payments.yookassa.shopId"),
+			SecretKey:       os.Getenv(viper.GetString("payments.yookassa.secretKeyEnv")),
+			WebhookNetworks: viper.GetStringSlice("payments.yookassa.webhookNetworks"),
+		})
+	case "fake":
+		if !devMode() {
+			return nil, errors.New("fake payment provider is only allowed with DEV_MODE=true")
+		}
+		webhookSecret := os.Getenv(viper.GetString("payments.fake.webhookSecretEnv"))
+		if webhookSecret == "" {
+			return nil, errors.New("fake payment provider requires a webhook secret")
+		}
+		return service.NewFakePaymentProvider(webhookSecret, viper.GetBool("payments.fake.autoSucceed")), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", provider)
+	}
+}
+
+func NewDeliveryProvider() service.DeliveryProvider {
+	switch viper.GetString("delivery.provider") {
+	case "cdek":
+		return service.NewCDEKProvider(service.CDEKConfig{
+			URL:          viper.GetString("delivery.cdek.url"),
+			ClientID:     viper.GetString("delivery.cdek.clientId"),
+			ClientSecret: os.Getenv(viper.GetString("delivery.cdek.clientSecretEnv")),
+			TariffCode:   viper.GetInt("delivery.cdek.tariffCode"),
+			FromIndex:    viper.GetInt("delivery.cdek.fromIndex"),
+		})
+	default:
+		return service.NewFakeDeliveryProvider()
+	}
+}
+
+func NewRateLimitStore(repo *repository.Repository) service.RateLimitStore {
+	switch viper.GetString("rateLimits.store") {
+	case "postgres":
+		return service.NewPostgresRateLimitStore(repo)
+	default:
+		return service.NewMemoryRateLimitStore()
+	}
+}
+
+func NewMailer() service.Mailer {
+	switch viper.GetString("mail.provider") {
+	case "smtp":
+		return service.NewSMTPMailer(service.SMTPConfig{
+			Host:     viper.GetString("mail.smtp.host"),
+			Port:     viper.GetInt("mail.smtp.port"),
+			Username: viper.GetString("mail.smtp.username"),
+			Password: os.Getenv(viper.GetString("mail.smtp.passwordEnv")),
+			From:     viper.GetString("mail.from"),
+		})
+	default:
+		return service.NewOutboxMailer(viper.GetString("mail.from"), viper.GetString("mail.outbox.dir"))
+	}
+}
+
+type signingKeyConfig struct {
+	ID             string `mapstructure:"id"`
+	Algorithm      string `mapstructure:"algorithm"`
+	SecretEnv      string `mapstructure:"secretEnv"`
+	PrivateKeyFile string `mapstructure:"privateKeyFile"`
+}
+
+func NewSigningKeys() (*service.KeySet, error) {
+	var keys []signingKeyConfig
+	if err := viper.UnmarshalKey("auth.keys", &keys); err != nil {
+		return nil, err
+	}
+	configs := make([]service.SigningKeyConfig, 0, len(keys))
+	for _, key := range keys {
+		config := service.SigningKeyConfig{
+			ID:        key.ID,
+			Algorithm: key.Algorithm,
+		}
+		if key.SecretEnv != "" {
+			config.Secret = os.Getenv(key.SecretEnv)
+		}
+		if key.PrivateKeyFile != "" {
+			privateKey, err := os.ReadFile(key.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			config.PrivateKeyPEM = privateKey
+		}
+		configs = append(configs, config)
+	}
+	return service.NewKeySet(configs)
+}
+
+type oauthProviderConfig struct {
+	Name               string   `mapstructure:"name"`
+	Enabled            bool     `mapstructure:"enabled"`
+	DevOnly            bool     `mapstructure:"devOnly"`
+	AuthURL            string   `mapstructure:"authUrl"`
+	TokenURL           string   `mapstructure:"tokenUrl"`
+	UserInfoURL        string   `mapstructure:"userInfoUrl"`
+	ClientID           string   `mapstructure:"clientId"`
+	ClientSecretEnv    string   `mapstructure:"clientSecretEnv"`
+	Scopes             []string `mapstructure:"scopes"`
+	UserInfoAuth       string   `mapstructure:"userInfoAuth"`
+	TokenParams        []string `mapstructure:"tokenParams"`
+	SubjectField       string   `mapstructure:"subjectField"`
+	EmailField         string   `mapstructure:"emailField"`
+	EmailVerifiedField string   `mapstructure:"emailVerifiedField"`
+	NameField          string   `mapstructure:"nameField"`
+	TrustEmail         bool     `mapstructure:"trustEmail"`
+}
+
+func NewOAuthConfig() (service.OAuthConfig, error) {
+	var providers []oauthProviderConfig
+	if err := viper.UnmarshalKey("oauth.providers", &providers); err != nil {
+		return service.OAuthConfig{}, err
+	}
+	config := service.OAuthConfig{
+		RedirectBaseURL: viper.GetString("oauth.redirectBaseUrl"),
+		FrontendURL:     viper.GetString("oauth.frontendUrl"),
+	}
+	for _, provider := range providers {
+		if provider.DevOnly {
+			provider.Enabled = devMode()
+			if provider.TrustEmail {
+				return service.OAuthConfig{}, fmt.Errorf("oauth provider %q is for development only and can't trust emails", provider.Name)
+			}
+		}
+		if !provider.Enabled {
+			continue
+		}
+		providerConfig := service.OAuthProviderConfig{
+			Name:               provider.Name,
+			AuthURL:            provider.AuthURL,
+			TokenURL:           provider.TokenURL,
+			UserInfoURL:        provider.UserInfoURL,
+			ClientID:           provider.ClientID,
+			Scopes:             provider.Scopes,
+			UserInfoAuth:       provider.UserInfoAuth,
+			TokenParams:        provider.TokenParams,
+			SubjectField:       provider.SubjectField,
+			EmailField:         provider.EmailField,
+			EmailVerifiedField: provider.EmailVerifiedField,
+			NameField:          provider.NameField,
+			TrustEmail:         provider.TrustEmail,
+		}
+		if provider.ClientSecretEnv != "" {
+			providerConfig.ClientSecret = os.Getenv(provider.ClientSecretEnv)
+		}
+		config.Providers = append(config.Providers, providerConfig)
+	}
+	return config, nil
+}
+
 func InitConfig() error {
 	viper.AddConfigPath("configs")
 	viper.SetConfigName("config")
@@ -0,0 +1,140 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+type AddressInput struct {
+	RecipientName string `json:"recipient_name" binding:"required" validate:"required,max=255"`
+	Phone         string `json:"phone" binding:"required" validate:"required,e164"`
+	City          string `json:"city" binding:"required" validate:"required,max=255"`
+	Street        string `json:"street" binding:"required" validate:"required,max=255"`
+	PostalIndex   int    `json:"postal_index" binding:"required"`
+	IsDefault     bool   `json:"is_default"`
+}
+
+func (i AddressInput) toModel() model.Address {
+	return model.Address{
+		RecipientName: i.RecipientName,
+		Phone:         i.Phone,
+		City:          i.City,
+		Street:        i.Street,
+		PostalIndex:   i.PostalIndex,
+		IsDefault:     i.IsDefault,
+	}
+}
+
+func (e *Endpoint) CreateAddress(c *gin.Context) {
+	var input AddressInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	addressId, err := e.services.Addresses.CreateAddress(userId, input.toModel())
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"address_id": addressId,
+	})
+}
+
+func (e *Endpoint) GetAddresses(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	addresses, err := e.services.Addresses.GetAddresses(userId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"addresses": addresses,
+	})
+}
+
+func (e *Endpoint) GetAddress(c *gin.Context) {
+	addressId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	address, err := e.services.Addresses.GetAddress(userId, addressId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"address": address,
+	})
+}
+
+func (e *Endpoint) UpdateAddress(c *gin.Context) {
+	addressId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	var input AddressInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Addresses.UpdateAddress(userId, addressId, input.toModel()); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Address updated successfully",
+	})
+}
+
+func (e *Endpoint) DeleteAddress(c *gin.Context) {
+	addressId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Addresses.DeleteAddress(userId, addressId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Address deleted successfully",
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/service"
 )
 
 var validate *validator.Validate
@@ -18,6 +19,7 @@ type SignUpInput struct {
 	Name     string `json:"name" binding:"required" validate:"required,min=2,max=255"`
 	Email    string `json:"email" binding:"required" validate:"required,email,max=255"`
 	Password string `json:"password" binding:"required" validate:"required,min=8,max=72"`
+	Locale   string `json:"locale" validate:"omitempty,oneof=ru en"`
 }
 
 type SignInInput struct {
@@ -41,11 +43,12 @@ func (e *Endpoint) SignUp(c *gin.Context) {
 		return
 	}
 
-	userId, err := e.services.Auth.SignUp(model.User{Name: input.Name, Email: input.Email, Password: input.Password})
+	userId, err := e.services.Auth.SignUp(c, model.User{Name: input.Name, Email: input.Email, Password: input.Password, Locale: input.Locale})
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
+	e.mergeGuestCart(c, userId)
 
 	c.JSON(http.StatusOK, map[string]interface{}{"id": userId})
 }
@@ -62,12 +65,23 @@ func (e *Endpoint) SignIn(c *gin.Context) {
 		return
 	}
 
-	access, refresh, err := e.services.Auth.SignIn(input.Email, input.Password)
+	result, err := e.services.Auth.SignIn(input.Email, input.Password, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		setRetryAfter(c, err)
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, map[string]interface{}{"access_token": access, "refresh_token": refresh})
+	if result.ChallengeToken == "" {
+		e.mergeGuestCart(c, result.UserID)
+	}
+	c.JSON(http.StatusOK, signInResponse(result))
+}
+
+func signInResponse(result service.SignInResult) map[string]interface{} {
+	if result.ChallengeToken != "" {
+		return map[string]interface{}{"two_factor_required": true, "challenge_token": result.ChallengeToken}
+	}
+	return map[string]interface{}{"access_token": result.AccessToken, "refresh_token": result.RefreshToken}
 }
 
 func (e *Endpoint) Refresh(c *gin.Context) {
@@ -76,14 +90,87 @@ func (e *Endpoint) Refresh(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	access, refresh, err := e.services.Auth.Refresh(input.RefreshToken)
+	access, refresh, err := e.services.Auth.Refresh(input.RefreshToken, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"access_token": access, "refresh_token": refresh})
 }
 
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (e *Endpoint) VerifyEmail(c *gin.Context) {
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.VerifyEmail(input.Token); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Email verified successfully"})
+}
+
+func (e *Endpoint) ResendVerificationEmail(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.ResendVerificationEmail(c, userId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Verification email sent"})
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required" validate:"required,email,max=255"`
+}
+
+func (e *Endpoint) ForgotPassword(c *gin.Context) {
+	var input ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.ForgotPassword(c, input.Email); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "If the email is registered, a reset link has been sent"})
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required" validate:"required,min=8,max=72"`
+}
+
+func (e *Endpoint) ResetPassword(c *gin.Context) {
+	var input ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.ResetPassword(input.Token, input.Password); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Password reset successfully"})
+}
+
 type SetRoleInput struct {
 	UserId int `json:"user_id" binding:"required"`
 }
@@ -100,7 +187,7 @@ func (e *Endpoint) NewAdmin(c *gin.Context) {
 		return
 	}
 	if err = e.services.Auth.NewAdmin(userId, input.UserId); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"message": "Admin set successfully"})
@@ -118,7 +205,7 @@ func (e *Endpoint) NewBuyer(c *gin.Context) {
 		return
 	}
 	if err = e.services.Auth.NewBuyer(userId, input.UserId); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"message": "Buyer set successfully"})
@@ -136,7 +223,7 @@ func (e *Endpoint) RemoveBuyer(c *gin.Context) {
 		return
 	}
 	if err = e.services.Auth.RemoveBuyer(userId, input.UserId); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"message": "Buyer removed successfully"})
@@ -148,6 +235,10 @@ func (e *Endpoint) GetUserRole(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
 		return
 	}
+	if role := e.GetRole(c); role != "" {
+		c.JSON(http.StatusOK, map[string]interface{}{"role": role})
+		return
+	}
 	role, err := e.services.Auth.GetUserRole(userId)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
@@ -178,3 +269,7 @@ func (e *Endpoint) GetUserIdByToken(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"user_id": userId})
 }
+
+func (e *Endpoint) GetJWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, e.services.Auth.JWKS())
+}
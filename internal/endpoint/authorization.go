@@ -0,0 +1,63 @@
+package endpoint
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/service"
+)
+
+var ErrForbidden = errors.New("insufficient permissions")
+
+// twoFactorSatisfied reports whether the caller's role may act without 2FA
+// or the access token was issued after a second factor.
+func (e *Endpoint) twoFactorSatisfied(c *gin.Context) bool {
+	return e.HasTwoFactor(c) || !e.services.Auth.TwoFactorRequired(e.GetRole(c))
+}
+
+func (e *Endpoint) HasPermission(c *gin.Context, permission service.Permission) bool {
+	return service.RoleHasPermission(e.GetRole(c), permission) && e.twoFactorSatisfied(c)
+}
+
+// orderActor returns the caller with the permissions of their role, which the
+// order service checks for actions on other users' orders. Staff who have not
+// passed 2FA act with customer permissions only.
+func (e *Endpoint) orderActor(c *gin.Context) (service.OrderActor, error) {
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		return service.OrderActor{}, ErrNotAuthorized
+	}
+	actor := service.OrderActor{UserID: userId}
+	if e.twoFactorSatisfied(c) {
+		actor.Permissions = service.RolePermissions(e.GetRole(c))
+	}
+	return actor, nil
+}
+
+func (e *Endpoint) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := e.GetUserId(c)
+		if err != nil || userId == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+			return
+		}
+	}
+}
+
+func (e *Endpoint) RequirePermission(permission service.Permission) gin.HandlerFunc {
+	requireAuth := e.RequireAuth()
+	return func(c *gin.Context) {
+		requireAuth(c)
+		if c.IsAborted() {
+			return
+		}
+		if !service.RoleHasPermission(e.GetRole(c), permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{Message: ErrForbidden.Error()})
+			return
+		}
+		if !e.twoFactorSatisfied(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{Message: service.ErrTwoFactorRequired.Error()})
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const dateLayout = "2006-01-02"
+
+func parseTimeQuery(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func parseOrdersFilter(c *gin.Context) (model.OrdersFilter, error) {
+	filter := model.OrdersFilter{
+		Status: c.Query("status"),
+		Page:   1,
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	if shopPointId, err := strconv.Atoi(c.Query("shop_point_id")); err == nil {
+		filter.ShopPointID = shopPointId
+	}
+	from, err := parseTimeQuery(c.Query("from"))
+	if err != nil {
+		return model.OrdersFilter{}, err
+	}
+	to, err := parseTimeQuery(c.Query("to"))
+	if err != nil {
+		return model.OrdersFilter{}, err
+	}
+	if to != nil && len(c.Query("to")) == len(dateLayout) {
+		endOfDay := to.AddDate(0, 0, 1)
+		to = &endOfDay
+	}
+	filter.From = from
+	filter.To = to
+	return filter, nil
+}
+
+func (e *Endpoint) GetDeliveryOrders(c *gin.Context) {
+	filter, err := parseOrdersFilter(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	orders, err := e.services.Orders.GetDeliveryOrders(filter)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"orders": orders,
+		"page":   filter.Page,
+	})
+}
+
+func (e *Endpoint) GetPickupOrders(c *gin.Context) {
+	filter, err := parseOrdersFilter(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	orders, err := e.services.Orders.GetPickupOrders(filter)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"orders": orders,
+		"page":   filter.Page,
+	})
+}
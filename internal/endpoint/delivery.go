@@ -0,0 +1,45 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (e *Endpoint) GetDeliveryQuote(c *gin.Context) {
+	index, err := strconv.Atoi(c.Query("index"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	quote, err := e.services.Delivery.Quote(c, userId, index)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"quote": quote,
+	})
+}
+
+func (e *Endpoint) CreateShipment(c *gin.Context) {
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	shipmentId, err := e.services.Delivery.CreateShipment(c, orderId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"delivery_id": shipmentId,
+	})
+}
@@ -28,64 +28,161 @@ func (e *Endpoint) InitRoutes() *gin.Engine {
 	config := cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Idempotency-Key", cartTokenHeader},
+		ExposeHeaders:    []string{cartTokenHeader, "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
 
 	router.Use(cors.New(config))
-	auth := router.Group("/auth")
+	auth := router.Group("/auth", e.RateLimit("auth"))
 	{
 		auth.POST("/sign-up", e.SignUp)
 		auth.POST("/sign-in", e.SignIn)
+		auth.POST("/sign-in/2fa", e.SignInTwoFactor)
+		auth.GET("/oauth/:provider/start", e.OAuthStart)
+		auth.GET("/oauth/:provider/callback", e.OAuthCallback)
 		auth.POST("/refresh", e.Refresh)
+		auth.POST("/logout", e.Logout)
+		auth.POST("/verify-email", e.VerifyEmail)
+		auth.POST("/forgot-password", e.ForgotPassword)
+		auth.POST("/reset-password", e.ResetPassword)
+		auth.POST("/confirm-email", e.ConfirmEmailChange)
+		auth.POST("/logout-all", e.Middleware, e.RequireAuth(), e.LogoutAll)
 	}
-	api := router.Group("/api", e.Middleware)
+	router.GET("/.well-known/jwks.json", e.GetJWKS)
+	router.POST("/payments/webhook", e.PaymentWebhook)
+	api := router.Group("/api", e.Middleware, e.RateLimit("api"))
 	{
-		api.POST("/new-admin", e.NewAdmin)
-		api.POST("/new-buyer", e.NewBuyer)
-		api.GET("/my-id", e.GetUserIdByToken)
-		api.GET("/my-role", e.GetUserRole)
-		api.GET("/my-user", e.GetUser)
-		api.DELETE("/remove-buyer", e.RemoveBuyer)
+		api.POST("/new-admin", e.RequirePermission(service.UsersManage), e.NewAdmin)
+		api.POST("/new-buyer", e.RequirePermission(service.UsersManage), e.NewBuyer)
+		api.GET("/my-id", e.RequireAuth(), e.GetUserIdByToken)
+		api.GET("/my-role", e.RequireAuth(), e.GetUserRole)
+		api.GET("/my-user", e.RequireAuth(), e.GetUser)
+		api.PATCH("/my-user", e.RequireAuth(), e.UpdateUser)
+		api.DELETE("/my-user", e.RequireAuth(), e.DeleteUser)
+		api.POST("/my-user/verify-email", e.RequireAuth(), e.ResendVerificationEmail)
+		api.POST("/my-user/email", e.RequireAuth(), e.RequestEmailChange)
+		api.POST("/my-user/password", e.RequireAuth(), e.ChangePassword)
+		api.POST("/my-user/reauth", e.RequireAuth(), e.RequestReauth)
+		api.GET("/my-user/identities", e.RequireAuth(), e.GetUserIdentities)
+		api.DELETE("/remove-buyer", e.RequirePermission(service.UsersManage), e.RemoveBuyer)
+		api.GET("/sessions", e.RequireAuth(), e.GetSessions)
+		api.DELETE("/sessions/:id", e.RequireAuth(), e.RevokeSession)
+	}
+	twoFactor := api.Group("/2fa", e.RequireAuth())
+	{
+		twoFactor.POST("/setup", e.SetupTwoFactor)
+		twoFactor.POST("/confirm", e.ConfirmTwoFactor)
+		twoFactor.POST("/disable", e.DisableTwoFactor)
+		twoFactor.POST("/recovery-codes", e.RegenerateRecoveryCodes)
 	}
 	products := api.Group("/products")
 	{
 		// Сначала идут статические маршруты
 		products.GET("/collections", e.GetCollections)
-		products.POST("/collections", e.CreateCollection)
-		products.GET("/cart", e.GetProductsInCart)
-		products.GET("/liked", e.GetLikedProducts)
-		products.GET("/search", e.SearchProducts)
+		products.POST("/collections", e.RequirePermission(service.ProductsWrite), e.CreateCollection)
+		products.GET("/cart", e.GuestCart, e.GetProductsInCart)
+		products.GET("/liked", e.RequireAuth(), e.GetLikedProducts)
+		products.GET("/search", e.RateLimit("search"), e.SearchProducts)
 
 		// Затем маршруты с параметрами
-		products.POST("/:id/cart", e.AddProductToCart)
-		products.DELETE("/:id/cart", e.RemoveProductFromCart)
-		products.POST("/:id/liked", e.AddProductToLiked)
-		products.DELETE("/:id/liked", e.RemoveProductFromLiked)
-		products.PUT("/:id/sizes", e.UpdateProductSizes)
+		products.POST("/:id/cart", e.GuestCart, e.Idempotent, e.AddProductToCart)
+		products.DELETE("/:id/cart", e.GuestCart, e.RemoveProductFromCart)
+		products.POST("/:id/liked", e.RequireAuth(), e.AddProductToLiked)
+		products.DELETE("/:id/liked", e.RequireAuth(), e.RemoveProductFromLiked)
+		products.PUT("/:id/sizes", e.RequirePermission(service.ProductsWrite), e.UpdateProductSizes)
 		products.GET("/:id/sizes", e.GetProductSizes)
-		products.PUT("/:id/sizes/amount", e.ChangeProductSizesAmount)
+		products.PUT("/:id/sizes/amount", e.RequirePermission(service.ProductsWrite), e.ChangeProductSizesAmount)
+		products.GET("/:id/prices", e.RequirePermission(service.ProductsWrite), e.GetProductPrices)
+		products.POST("/:id/prices", e.RequirePermission(service.ProductsWrite), e.CreateProductPrice)
+		products.DELETE("/:id/prices/:price_id", e.RequirePermission(service.ProductsWrite), e.DeleteProductPrice)
 		products.GET("/:id", e.GetProduct)
-		products.DELETE("/:id", e.DeleteProduct)
+		products.DELETE("/:id", e.RequirePermission(service.ProductsWrite), e.DeleteProduct)
 
 		// В конце общие маршруты
-		products.POST("/", e.PostProduct)
+		products.POST("/", e.RequirePermission(service.ProductsWrite), e.PostProduct)
 		products.GET("/", e.GetProducts)
 	}
 	productsMedia := api.Group("/products-media")
 	{
-		productsMedia.POST("/", e.UploadOneProductMedia)
+		productsMedia.POST("/", e.RequirePermission(service.ProductsWrite), e.UploadOneProductMedia)
 		productsMedia.GET("/:product_id", e.GetProductMedia)
-		productsMedia.DELETE("/:media_id", e.DeleteOneProductMedia)
+		productsMedia.DELETE("/:media_id", e.RequirePermission(service.ProductsWrite), e.DeleteOneProductMedia)
+	}
+	orders := api.Group("/orders", e.RequireAuth())
+	{
+		orders.POST("/", e.Idempotent, e.CreateOrder)
+		orders.GET("/", e.GetUserOrders)
+		orders.GET("/:id", e.GetOrder)
+		orders.PUT("/:id/status", e.SetOrderStatus)
+		orders.GET("/:id/history", e.GetOrderStatusHistory)
+		orders.POST("/:id/cancel", e.Idempotent, e.CancelOrder)
+		orders.POST("/:id/returns", e.Idempotent, e.ReturnOrderedProducts)
+		orders.POST("/:id/payment", e.Idempotent, e.CreateOrderPayment)
+		orders.GET("/:id/payment", e.SyncOrderPayment)
+		orders.POST("/:id/shipment", e.RequirePermission(service.OrdersFulfil), e.CreateShipment)
+	}
+	backofficeOrders := api.Group("/backoffice/orders", e.RequirePermission(service.OrdersReadAll))
+	{
+		backofficeOrders.GET("/delivery", e.GetDeliveryOrders)
+		backofficeOrders.GET("/pickup", e.GetPickupOrders)
+		backofficeOrders.GET("/:id", e.GetOrder)
+		backofficeOrders.GET("/:id/history", e.GetOrderStatusHistory)
+		backofficeOrders.PUT("/:id/status", e.RequirePermission(service.OrdersFulfil), e.SetOrderStatus)
+		backofficeOrders.POST("/:id/shipment", e.RequirePermission(service.OrdersFulfil), e.CreateShipment)
+		backofficeOrders.POST("/:id/returns", e.RequirePermission(service.OrdersRefund), e.Idempotent, e.ReturnOrderedProducts)
+	}
+	shopPoints := api.Group("/shop-points")
+	{
+		shopPoints.GET("/", e.GetShopPoints)
+		shopPoints.GET("/:id", e.GetShopPoint)
+		shopPoints.POST("/", e.RequirePermission(service.ShopPointsWrite), e.CreateShopPoint)
+		shopPoints.PUT("/:id", e.RequirePermission(service.ShopPointsWrite), e.UpdateShopPoint)
+		shopPoints.DELETE("/:id", e.RequirePermission(service.ShopPointsWrite), e.DeleteShopPoint)
+	}
+	promoCodes := api.Group("/promo-codes", e.RequirePermission(service.PromoCodesWrite))
+	{
+		promoCodes.GET("/", e.GetPromoCodes)
+		promoCodes.GET("/:id", e.GetPromoCode)
+		promoCodes.POST("/", e.CreatePromoCode)
+		promoCodes.PUT("/:id", e.UpdatePromoCode)
+		promoCodes.DELETE("/:id", e.DeletePromoCode)
+	}
+	cart := api.Group("/cart")
+	{
+		cart.POST("/apply-promo", e.RequireAuth(), e.ApplyPromoCode)
+		cart.PATCH("/lines/:id", e.GuestCart, e.UpdateCartLine)
+		cart.DELETE("/lines/:id", e.GuestCart, e.RemoveCartLine)
+	}
+	checkout := api.Group("/checkout", e.RequireAuth())
+	{
+		checkout.POST("/", e.StartCheckout)
+	}
+	delivery := api.Group("/delivery", e.RequireAuth())
+	{
+		delivery.GET("/quote", e.GetDeliveryQuote)
 	}
 	reviews := api.Group("/reviews")
 	{
-		reviews.POST("/", e.CreateReview)
+		reviews.POST("/", e.RequireAuth(), e.CreateReview)
 		reviews.GET("/:product_id", e.GetReviews)
-		reviews.DELETE("/:id", e.DeleteReview)
-		reviews.PUT("/:id", e.UpdateReview)
+		reviews.DELETE("/:id", e.RequireAuth(), e.DeleteReview)
+		reviews.PUT("/:id", e.RequireAuth(), e.UpdateReview)
 		reviews.GET("/:product_id/rating", e.GetProductRating)
 	}
+	addresses := api.Group("/addresses", e.RequireAuth())
+	{
+		addresses.GET("/", e.GetAddresses)
+		addresses.POST("/", e.CreateAddress)
+		addresses.GET("/:id", e.GetAddress)
+		addresses.PUT("/:id", e.UpdateAddress)
+		addresses.DELETE("/:id", e.DeleteAddress)
+	}
+	notifications := api.Group("/notifications", e.RequirePermission(service.NotificationsManage))
+	{
+		notifications.GET("/", e.GetNotifications)
+		notifications.POST("/:id/resend", e.ResendNotification)
+	}
 	return router
 }
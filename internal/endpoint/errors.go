@@ -0,0 +1,101 @@
+package endpoint
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/lavatee/dresscode_backend/internal/service"
+)
+
+var ErrNotAuthorized = errors.New("authorization required")
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidWebhookSignature),
+		errors.Is(err, service.ErrInvalidCredentials),
+		errors.Is(err, service.ErrInvalidRefreshToken),
+		errors.Is(err, service.ErrRefreshTokenReused),
+		errors.Is(err, service.ErrInvalidTwoFactorChallenge),
+		errors.Is(err, service.ErrInvalidOAuthState):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrOrderNotFound),
+		errors.Is(err, service.ErrPaymentNotFound),
+		errors.Is(err, service.ErrShipmentNotFound),
+		errors.Is(err, service.ErrShopPointNotFound),
+		errors.Is(err, service.ErrPromoCodeNotFound),
+		errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, service.ErrProductPriceNotFound),
+		errors.Is(err, service.ErrSessionNotFound),
+		errors.Is(err, service.ErrReviewNotFound),
+		errors.Is(err, service.ErrNotificationNotFound),
+		errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrAddressNotFound),
+		errors.Is(err, service.ErrCartLineNotFound),
+		errors.Is(err, service.ErrUnknownOAuthProvider):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrOrderAccessDenied),
+		errors.Is(err, ErrForbidden),
+		errors.Is(err, service.ErrWrongPassword),
+		errors.Is(err, service.ErrTwoFactorRequired),
+		errors.Is(err, service.ErrUserNotBuyer),
+		errors.Is(err, service.ErrOrderTransitionForbidden),
+		errors.Is(err, service.ErrOrderCancelForbidden),
+		errors.Is(err, service.ErrUntrustedWebhookSource),
+		errors.Is(err, service.ErrEmailNotVerified):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrEmptyCart),
+		errors.Is(err, service.ErrCartProductUnavailable),
+		errors.Is(err, service.ErrInvalidOrderType),
+		errors.Is(err, service.ErrShopPointRequired),
+		errors.Is(err, service.ErrDeliveryAddressMissing),
+		errors.Is(err, service.ErrInvalidReturnedProduct),
+		errors.Is(err, service.ErrInvalidPostalIndex),
+		errors.Is(err, service.ErrShopPointInactive),
+		errors.Is(err, service.ErrInvalidPromoCode),
+		errors.Is(err, service.ErrInvalidProductPrice),
+		errors.Is(err, service.ErrPromoCodeInactive),
+		errors.Is(err, service.ErrPromoCodeMinOrderSum),
+		errors.Is(err, service.ErrPromoCodeNotApplicable),
+		errors.Is(err, service.ErrInvalidUserToken),
+		errors.Is(err, service.ErrSameEmail),
+		errors.Is(err, service.ErrInvalidTwoFactorCode),
+		errors.Is(err, service.ErrTwoFactorSetupMissing),
+		errors.Is(err, service.ErrOAuthDenied),
+		errors.Is(err, service.ErrOAuthEmailRequired),
+		errors.Is(err, service.ErrOAuthEmailNotVerified),
+		errors.Is(err, service.ErrTooManyAddresses),
+		errors.Is(err, service.ErrAddressWithoutIndex),
+		errors.Is(err, service.ErrInvalidCartAmount),
+		errors.Is(err, repository.ErrInvalidReturnAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrNotEnoughStock),
+		errors.Is(err, repository.ErrOrderStatusChanged),
+		errors.Is(err, service.ErrIllegalOrderTransition),
+		errors.Is(err, service.ErrOrderNotCancellable),
+		errors.Is(err, service.ErrOrderNotReturnable),
+		errors.Is(err, service.ErrOrderNotPending),
+		errors.Is(err, service.ErrShipmentNotAllowed),
+		errors.Is(err, service.ErrShipmentAlreadySent),
+		errors.Is(err, repository.ErrShopPointInUse),
+		errors.Is(err, repository.ErrPromoCodeExists),
+		errors.Is(err, repository.ErrPromoCodeInUse),
+		errors.Is(err, repository.ErrPromoCodeLimitReached),
+		errors.Is(err, service.ErrEmailVerified),
+		errors.Is(err, service.ErrTwoFactorEnabled),
+		errors.Is(err, service.ErrTwoFactorNotEnabled),
+		errors.Is(err, repository.ErrEmailTaken),
+		errors.Is(err, repository.ErrIdentityExists),
+		errors.Is(err, service.ErrOAuthAccountUnverified),
+		errors.Is(err, service.ErrPasswordSet),
+		errors.Is(err, service.ErrIdempotencyKeyReused),
+		errors.Is(err, service.ErrIdempotencyKeyInFlight):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrRateLimited),
+		errors.Is(err, service.ErrTooManyLoginAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrOAuthProvider):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,82 @@
+package endpoint
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cartTokenHeader = "X-Cart-Token"
+	cartTokenCookie = "cart_token"
+	cartCookiePath  = "/"
+)
+
+var errNoCart = errors.New("cart not found: sign in or use a cart token")
+
+func (e *Endpoint) cartToken(c *gin.Context) string {
+	if token := c.GetHeader(cartTokenHeader); token != "" {
+		return token
+	}
+	token, _ := c.Cookie(cartTokenCookie)
+	return token
+}
+
+// GuestCart gives anonymous visitors a cart. The token is accepted from the
+// X-Cart-Token header or the cart_token cookie and is reissued on every
+// request, so an active cart does not expire.
+func (e *Endpoint) GuestCart(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil || userId != 0 {
+		return
+	}
+	guestCartId := ""
+	if token := e.cartToken(c); token != "" {
+		guestCartId, _ = e.services.GuestCarts.ParseGuestCartToken(token)
+	}
+	guestCartId, token, err := e.services.GuestCarts.IssueGuestCartToken(guestCartId)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.Header(cartTokenHeader, token)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cartTokenCookie, token, int(e.services.GuestCarts.GuestCartTTL().Seconds()), cartCookiePath, "", secureRequest(c), true)
+	c.Set("guest_cart_id", guestCartId)
+}
+
+func (e *Endpoint) GetCartOwner(c *gin.Context) (model.CartOwner, error) {
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		return model.CartOwner{}, err
+	}
+	if userId != 0 {
+		return model.CartOwner{UserID: userId}, nil
+	}
+	guestCartId := c.GetString("guest_cart_id")
+	if guestCartId == "" {
+		return model.CartOwner{}, errNoCart
+	}
+	return model.CartOwner{GuestCartID: guestCartId}, nil
+}
+
+// mergeGuestCart moves the caller's guest cart into the user's cart after
+// sign in. A failed merge must not fail the sign in, so it is only logged.
+func (e *Endpoint) mergeGuestCart(c *gin.Context, userId int) {
+	token := e.cartToken(c)
+	if token == "" {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cartTokenCookie, "", -1, cartCookiePath, "", secureRequest(c), true)
+	guestCartId, err := e.services.GuestCarts.ParseGuestCartToken(token)
+	if err != nil {
+		return
+	}
+	if err := e.services.GuestCarts.MergeGuestCart(guestCartId, userId); err != nil {
+		logrus.Errorf("Failed to merge guest cart %s into user %d cart: %s", guestCartId, userId, err.Error())
+	}
+}
@@ -0,0 +1,105 @@
+package endpoint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	// maxIdempotentBodySize caps the request body that is buffered and hashed
+	// for the key, before any handler gets to reject it.
+	maxIdempotentBodySize = 1 << 20
+)
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+func (e *Endpoint) Idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: "idempotency key is too long"})
+		return
+	}
+	owner, err := e.GetCartOwner(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ErrorResponse{Message: err.Error()})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	scope := fmt.Sprintf("user:%d", owner.UserID)
+	if owner.UserID == 0 {
+		scope = "guest:" + owner.GuestCartID
+	}
+	record, replay, err := e.services.Idempotency.BeginRequest(scope, key, c.Request.Method, c.Request.URL.Path, body)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	if replay {
+		c.Header(idempotencyReplayedHeader, "true")
+		responseBody := ""
+		if record.ResponseBody != nil {
+			responseBody = *record.ResponseBody
+		}
+		c.Data(*record.ResponseStatus, "application/json; charset=utf-8", []byte(responseBody))
+		c.Abort()
+		return
+	}
+	// A panicking handler would otherwise leave the key in flight until it
+	// expires; release it and let the recovery middleware answer.
+	defer func() {
+		if r := recover(); r != nil {
+			if err := e.services.Idempotency.AbortRequest(record.ID); err != nil {
+				logrus.Errorf("error releasing idempotency key %s: %s", key, err.Error())
+			}
+			panic(r)
+		}
+	}()
+	recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+	c.Writer = recorder
+	c.Next()
+	status := recorder.Status()
+	if status >= http.StatusInternalServerError {
+		if err := e.services.Idempotency.AbortRequest(record.ID); err != nil {
+			logrus.Errorf("error releasing idempotency key %s: %s", key, err.Error())
+		}
+		return
+	}
+	if err := e.services.Idempotency.CompleteRequest(record.ID, status, recorder.body.String()); err != nil {
+		logrus.Errorf("error saving idempotent response for key %s: %s", key, err.Error())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/service"
 	"github.com/sirupsen/logrus"
 )
 
@@ -18,18 +19,35 @@ func (e *Endpoint) Middleware(c *gin.Context) {
 	}
 	claims, err := e.services.Auth.ParseToken(sliceOfHeaders[1])
 	if err != nil {
-		logrus.Errorf("Middleware error: " + err.Error())
-		if err.Error() == "expired" {
+		logrus.Errorf("Middleware error: %s", err.Error())
+		switch {
+		case errors.Is(err, service.ErrTokenExpired):
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Need to refresh token"})
-		} else {
-			c.Set("user_id", float64(0))
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrWrongTokenType):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
 		}
 		return
 	}
 	c.Set("user_id", claims["id"])
+	c.Set("role", claims["role"])
+	c.Set("mfa", claims["mfa"])
 	return
 }
 
+func (e *Endpoint) GetRole(c *gin.Context) string {
+	role, _ := c.Get("role")
+	roleString, _ := role.(string)
+	return roleString
+}
+
+func (e *Endpoint) HasTwoFactor(c *gin.Context) bool {
+	mfa, _ := c.Get("mfa")
+	mfaBool, _ := mfa.(bool)
+	return mfaBool
+}
+
 func (e *Endpoint) GetUserId(c *gin.Context) (int, error) {
 	userId, exists := c.Get("user_id")
 	if !exists {
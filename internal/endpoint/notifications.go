@@ -0,0 +1,46 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+func (e *Endpoint) GetNotifications(c *gin.Context) {
+	filter := model.NotificationsFilter{
+		Status: c.Query("status"),
+		Page:   1,
+	}
+	if orderId, err := strconv.Atoi(c.Query("order_id")); err == nil {
+		filter.OrderID = orderId
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	notifications, err := e.services.Notifications.GetNotifications(filter)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"notifications": notifications,
+		"page":          filter.Page,
+	})
+}
+
+func (e *Endpoint) ResendNotification(c *gin.Context) {
+	notificationId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Notifications.ResendNotification(notificationId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Notification queued for resending",
+	})
+}
@@ -0,0 +1,86 @@
+package endpoint
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	oauthVerifierCookie = "oauth_verifier"
+	oauthCookiePath     = "/auth/oauth"
+	oauthCookieMaxAge   = 10 * 60
+)
+
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// OAuthStart keeps the PKCE verifier in an HTTP-only cookie and sends the
+// browser to the provider.
+func (e *Endpoint) OAuthStart(c *gin.Context) {
+	authURL, verifier, err := e.services.Auth.StartOAuth(c.Param("provider"))
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthVerifierCookie, verifier, oauthCookieMaxAge, oauthCookiePath, "", secureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func (e *Endpoint) OAuthCallback(c *gin.Context) {
+	verifier, _ := c.Cookie(oauthVerifierCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthVerifierCookie, "", -1, oauthCookiePath, "", secureRequest(c), true)
+	result, err := e.services.Auth.FinishOAuth(c, c.Param("provider"), c.Request.URL.Query(), verifier, c.Request.UserAgent(), c.ClientIP())
+	if err == nil && result.ChallengeToken == "" {
+		e.mergeGuestCart(c, result.UserID)
+	}
+	if frontendURL := e.services.Auth.OAuthFrontendURL(); frontendURL != "" {
+		c.Redirect(http.StatusFound, oauthResultURL(frontendURL, result, err))
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, signInResponse(result))
+}
+
+// oauthResultURL passes the result to the frontend in the URL fragment, which
+// browsers don't send to servers or in the Referer header.
+func oauthResultURL(frontendURL string, result service.SignInResult, err error) string {
+	fragment := url.Values{}
+	switch {
+	case err != nil:
+		if errorStatus(err) == http.StatusInternalServerError {
+			logrus.Errorf("oauth callback error: %s", err.Error())
+		}
+		fragment.Set("error", err.Error())
+	case result.ChallengeToken != "":
+		fragment.Set("two_factor_required", "true")
+		fragment.Set("challenge_token", result.ChallengeToken)
+	default:
+		fragment.Set("access_token", result.AccessToken)
+		fragment.Set("refresh_token", result.RefreshToken)
+	}
+	return frontendURL + "#" + fragment.Encode()
+}
+
+func (e *Endpoint) GetUserIdentities(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	identities, err := e.services.Auth.GetUserIdentities(userId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"identities": identities})
+}
@@ -0,0 +1,214 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+type CreateOrderInput struct {
+	Type            string  `json:"type" binding:"required" validate:"required,oneof=delivery pickup"`
+	ShopPointID     *int    `json:"shop_point_id"`
+	DeliveryAddress string  `json:"delivery_address" validate:"max=255"`
+	DeliveryIndex   int     `json:"delivery_index" validate:"min=0"`
+	AddressID       *int    `json:"address_id"`
+	PromoCode       *string `json:"promo_code" validate:"omitempty,max=64"`
+}
+
+func (e *Endpoint) CreateOrder(c *gin.Context) {
+	var input CreateOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	order, err := e.services.Orders.CreateOrder(c, userId, model.Order{
+		Type:            input.Type,
+		ShopPointID:     input.ShopPointID,
+		DeliveryAddress: input.DeliveryAddress,
+		DeliveryIndex:   input.DeliveryIndex,
+		AddressID:       input.AddressID,
+		PromoCode:       input.PromoCode,
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	var payment *service.Payment
+	createdPayment, err := e.services.Payments.CreateOrderPayment(c, userId, order.ID)
+	if err != nil {
+		logrus.Errorf("error creating payment for order %d: %s", order.ID, err.Error())
+	} else {
+		payment = &createdPayment
+		order.PaymentID = createdPayment.ID
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"order":   order,
+		"payment": payment,
+	})
+}
+
+func (e *Endpoint) GetUserOrders(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	orders, err := e.services.Orders.GetUserOrders(userId, c.Query("status"), c.Query("type"))
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"orders": orders,
+	})
+}
+
+func (e *Endpoint) GetOrder(c *gin.Context) {
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	actor, err := e.orderActor(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	order, err := e.services.Orders.GetOrder(actor, orderId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"order": order,
+	})
+}
+
+type SetOrderStatusInput struct {
+	Status string `json:"status" binding:"required"`
+}
+
+func (e *Endpoint) SetOrderStatus(c *gin.Context) {
+	var input SetOrderStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	actor, err := e.orderActor(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Orders.SetOrderStatus(actor, orderId, input.Status); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Order status changed successfully",
+	})
+}
+
+func (e *Endpoint) GetOrderStatusHistory(c *gin.Context) {
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	actor, err := e.orderActor(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	history, err := e.services.Orders.GetOrderStatusHistory(actor, orderId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"history": history,
+	})
+}
+
+func (e *Endpoint) CancelOrder(c *gin.Context) {
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	actor, err := e.orderActor(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Orders.CancelOrder(c, actor, orderId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Order cancelled successfully",
+	})
+}
+
+type ReturnOrderedProductsInput struct {
+	Products []model.ReturnedProduct `json:"products" binding:"required"`
+}
+
+func (e *Endpoint) ReturnOrderedProducts(c *gin.Context) {
+	var input ReturnOrderedProductsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	actor, err := e.orderActor(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	fullyReturned, err := e.services.Orders.ReturnOrderedProducts(c, actor, orderId, input.Products)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"fully_returned": fullyReturned,
+	})
+}
+
+func (e *Endpoint) StartCheckout(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	reservations, err := e.services.Orders.StartCheckout(userId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"reservations": reservations,
+	})
+}
@@ -0,0 +1,75 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+const paymentSignatureHeader = "X-Payment-Signature"
+
+func (e *Endpoint) PaymentWebhook(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	request := service.WebhookRequest{
+		Body:      body,
+		Signature: c.GetHeader(paymentSignatureHeader),
+		RemoteIP:  c.ClientIP(),
+	}
+	if err := e.services.Payments.HandleWebhook(c, request); err != nil {
+		logrus.Errorf("payment webhook error: %s", err.Error())
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "ok",
+	})
+}
+
+func (e *Endpoint) CreateOrderPayment(c *gin.Context) {
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	payment, err := e.services.Payments.CreateOrderPayment(c, userId, orderId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"payment": payment,
+	})
+}
+
+func (e *Endpoint) SyncOrderPayment(c *gin.Context) {
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	status, err := e.services.Payments.SyncOrderPayment(c, userId, orderId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"status": status,
+	})
+}
@@ -0,0 +1,87 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+type ProductPriceInput struct {
+	Price          int        `json:"price" binding:"required" validate:"required,min=1"`
+	CompareAtPrice *int       `json:"compare_at_price" validate:"omitempty,min=1"`
+	EffectiveFrom  *time.Time `json:"effective_from"`
+	EffectiveTo    *time.Time `json:"effective_to"`
+}
+
+func (e *Endpoint) CreateProductPrice(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	var input ProductPriceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	price := model.ProductPrice{
+		ProductID:      productId,
+		Price:          input.Price,
+		CompareAtPrice: input.CompareAtPrice,
+		EffectiveTo:    input.EffectiveTo,
+	}
+	if input.EffectiveFrom != nil {
+		price.EffectiveFrom = *input.EffectiveFrom
+	}
+	priceId, err := e.services.Products.CreateProductPrice(price)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"price_id": priceId,
+	})
+}
+
+func (e *Endpoint) GetProductPrices(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	prices, err := e.services.Products.GetProductPrices(productId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"prices": prices,
+	})
+}
+
+func (e *Endpoint) DeleteProductPrice(c *gin.Context) {
+	productId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	priceId, err := strconv.Atoi(c.Param("price_id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Products.DeleteProductPrice(productId, priceId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Product price deleted successfully",
+	})
+}
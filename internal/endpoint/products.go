@@ -16,6 +16,7 @@ type CreateProductInput struct {
 	CollectionID int          `json:"collection_id"`
 	Category     string       `json:"category" binding:"required"`
 	Color        string       `json:"color" binding:"required"`
+	Weight       int          `json:"weight"`
 	Sizes        []model.Size `json:"sizes"`
 }
 
@@ -25,22 +26,18 @@ func (e *Endpoint) PostProduct(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
-		return
-	}
-	productId, err := e.services.Products.CreateProduct(userId, model.Product{
+	productId, err := e.services.Products.CreateProduct(model.Product{
 		Name:         input.Name,
 		Description:  input.Description,
 		Price:        input.Price,
 		CollectionID: input.CollectionID,
 		Category:     input.Category,
 		Color:        input.Color,
+		Weight:       input.Weight,
 		Sizes:        input.Sizes,
 	})
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"product_id": productId})
@@ -91,6 +88,7 @@ func (e *Endpoint) GetProducts(c *gin.Context) {
 	if err != nil {
 		maxPrice = 0
 	}
+	onSale := c.Query("on_sale") == "true"
 	page, err := strconv.Atoi(c.Query("page"))
 	if err != nil {
 		page = 1
@@ -99,7 +97,7 @@ func (e *Endpoint) GetProducts(c *gin.Context) {
 	// Пытаемся получить userId, но не возвращаем ошибку если токен отсутствует
 	userId, _ := e.GetUserId(c)
 
-	products, err := e.services.Products.GetProducts(collectionId, category, colors, sizes, minPrice, maxPrice, page, userId)
+	products, err := e.services.Products.GetProducts(collectionId, category, colors, sizes, minPrice, maxPrice, onSale, page, userId)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
 		return
@@ -115,14 +113,9 @@ func (e *Endpoint) DeleteProduct(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
-		return
-	}
-	err = e.services.Products.DeleteProduct(userId, productId)
+	err = e.services.Products.DeleteProduct(productId)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -146,13 +139,8 @@ func (e *Endpoint) UpdateProductSizes(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
-		return
-	}
-	if err = e.services.Products.UpdateProductSizes(userId, productId, input.RemovedSizes, input.AddedSizes); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+	if err = e.services.Products.UpdateProductSizes(productId, input.RemovedSizes, input.AddedSizes); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -182,14 +170,9 @@ func (e *Endpoint) CreateCollection(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
+	collectionId, err := e.services.Products.CreateCollection(input)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
-		return
-	}
-	collectionId, err := e.services.Products.CreateCollection(userId, input)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -224,14 +207,14 @@ func (e *Endpoint) AddProductToCart(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
+	owner, err := e.GetCartOwner(c)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
 		return
 	}
-	err = e.services.Products.AddProductToCart(userId, productId, input.Size, input.Amount)
+	err = e.services.Products.AddProductToCart(owner, productId, input.Size, input.Amount)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -245,12 +228,12 @@ func (e *Endpoint) RemoveProductFromCart(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
+	owner, err := e.GetCartOwner(c)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
 		return
 	}
-	err = e.services.Products.RemoveProductFromCart(userId, productId)
+	err = e.services.Products.RemoveProductFromCart(owner, productId, c.Query("size"))
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
 		return
@@ -261,18 +244,73 @@ func (e *Endpoint) RemoveProductFromCart(c *gin.Context) {
 }
 
 func (e *Endpoint) GetProductsInCart(c *gin.Context) {
-	userId, err := e.GetUserId(c)
+	owner, err := e.GetCartOwner(c)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
 		return
 	}
-	productsInCart, err := e.services.Products.GetProductsInCart(userId)
+	index := 0
+	if c.Query("index") != "" {
+		index, err = strconv.Atoi(c.Query("index"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+			return
+		}
+	}
+	cart, err := e.services.Products.GetProductsInCart(c, owner, index)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cart)
+}
+
+type UpdateCartLineInput struct {
+	Amount int `json:"amount" binding:"required"`
+}
+
+func (e *Endpoint) UpdateCartLine(c *gin.Context) {
+	var input UpdateCartLineInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	lineId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	owner, err := e.GetCartOwner(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Products.UpdateCartLine(owner, lineId, input.Amount); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Cart line updated successfully",
+	})
+}
+
+func (e *Endpoint) RemoveCartLine(c *gin.Context) {
+	lineId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	owner, err := e.GetCartOwner(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Products.RemoveCartLine(owner, lineId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
-		"products_in_cart": productsInCart,
+		"message": "Cart line removed successfully",
 	})
 }
 
@@ -349,17 +387,12 @@ func (e *Endpoint) ChangeProductSizesAmount(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
-		return
-	}
 	sizesMap := make(map[int]int)
 	for _, size := range input.Sizes {
 		sizesMap[size.SizeID] = size.Amount
 	}
-	if err = e.services.Products.ChangeProductSizesAmount(userId, sizesMap); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+	if err := e.services.Products.ChangeProductSizesAmount(sizesMap); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -33,17 +33,12 @@ func (e *Endpoint) UploadOneProductMedia(c *gin.Context) {
 	}
 	defer file.Close()
 	fileName := header.Filename
-	userId, err := e.GetUserId(c)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
-		return
-	}
 	media := model.ProductMedia{
 		Type: mediaType,
 	}
-	mediaId, url, err := e.services.ProductsMedia.UploadOneProductMedia(c, userId, productId, media, fileName, isProductMain, file)
+	mediaId, url, err := e.services.ProductsMedia.UploadOneProductMedia(c, productId, media, fileName, isProductMain, file)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"media_id": mediaId, "url": url})
@@ -69,14 +64,9 @@ func (e *Endpoint) DeleteOneProductMedia(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	userId, err := e.GetUserId(c)
+	err = e.services.ProductsMedia.DeleteOneProductMedia(mediaId)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
-		return
-	}
-	err = e.services.ProductsMedia.DeleteOneProductMedia(userId, mediaId)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
@@ -0,0 +1,146 @@
+package endpoint
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+type UpdateUserInput struct {
+	Name   *string `json:"name" validate:"omitempty,min=2,max=255"`
+	Phone  *string `json:"phone" validate:"omitempty,e164"`
+	Locale *string `json:"locale" validate:"omitempty,oneof=ru en"`
+}
+
+func (e *Endpoint) UpdateUser(c *gin.Context) {
+	var input UpdateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	user, err := e.services.Auth.UpdateUser(userId, model.UserUpdate{
+		Name:   input.Name,
+		Phone:  input.Phone,
+		Locale: input.Locale,
+	})
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"user": user})
+}
+
+type ChangeEmailInput struct {
+	Email    string `json:"email" binding:"required" validate:"required,email,max=255"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (e *Endpoint) RequestEmailChange(c *gin.Context) {
+	var input ChangeEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.RequestEmailChange(c, userId, input.Email, input.Password); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Confirmation link sent to the new email"})
+}
+
+func (e *Endpoint) ConfirmEmailChange(c *gin.Context) {
+	var input VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.ConfirmEmailChange(input.Token); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Email changed successfully"})
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required" validate:"required,min=8,max=72"`
+}
+
+func (e *Endpoint) ChangePassword(c *gin.Context) {
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	access, refresh, err := e.services.Auth.ChangePassword(userId, input.CurrentPassword, input.NewPassword, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"access_token": access, "refresh_token": refresh})
+}
+
+type DeleteUserInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+func (e *Endpoint) DeleteUser(c *gin.Context) {
+	var input DeleteUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.DeleteUser(userId, input.Password); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Account deleted successfully"})
+}
+
+// RequestReauth emails a confirmation link to users who signed up with a
+// provider and have no password to confirm sensitive actions with.
+func (e *Endpoint) RequestReauth(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.RequestReauth(c, userId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Confirmation email sent"})
+}
@@ -0,0 +1,159 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+type PromoCodeInput struct {
+	Code              string     `json:"code" binding:"required" validate:"required,max=64"`
+	DiscountType      string     `json:"discount_type" binding:"required" validate:"required,oneof=percent fixed"`
+	Value             int        `json:"value" binding:"required" validate:"required,min=1"`
+	MinOrderSum       int        `json:"min_order_sum" validate:"min=0"`
+	ValidFrom         *time.Time `json:"valid_from"`
+	ValidTo           *time.Time `json:"valid_to"`
+	UsageLimit        *int       `json:"usage_limit" validate:"omitempty,min=1"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user" validate:"omitempty,min=1"`
+	CollectionID      *int       `json:"collection_id"`
+	Category          *string    `json:"category" validate:"omitempty,max=255"`
+	IsActive          *bool      `json:"is_active"`
+}
+
+func (i PromoCodeInput) toModel() model.PromoCode {
+	isActive := true
+	if i.IsActive != nil {
+		isActive = *i.IsActive
+	}
+	return model.PromoCode{
+		Code:              i.Code,
+		DiscountType:      i.DiscountType,
+		Value:             i.Value,
+		MinOrderSum:       i.MinOrderSum,
+		ValidFrom:         i.ValidFrom,
+		ValidTo:           i.ValidTo,
+		UsageLimit:        i.UsageLimit,
+		UsageLimitPerUser: i.UsageLimitPerUser,
+		CollectionID:      i.CollectionID,
+		Category:          i.Category,
+		IsActive:          isActive,
+	}
+}
+
+type ApplyPromoCodeInput struct {
+	Code string `json:"code" binding:"required" validate:"required,max=64"`
+}
+
+func (e *Endpoint) CreatePromoCode(c *gin.Context) {
+	var input PromoCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	promoCodeId, err := e.services.PromoCodes.CreatePromoCode(input.toModel())
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"promo_code_id": promoCodeId,
+	})
+}
+
+func (e *Endpoint) GetPromoCodes(c *gin.Context) {
+	promoCodes, err := e.services.PromoCodes.GetPromoCodes()
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"promo_codes": promoCodes,
+	})
+}
+
+func (e *Endpoint) GetPromoCode(c *gin.Context) {
+	promoCodeId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	promoCode, err := e.services.PromoCodes.GetPromoCode(promoCodeId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"promo_code": promoCode,
+	})
+}
+
+func (e *Endpoint) UpdatePromoCode(c *gin.Context) {
+	promoCodeId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	var input PromoCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.PromoCodes.UpdatePromoCode(promoCodeId, input.toModel()); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Promo code updated successfully",
+	})
+}
+
+func (e *Endpoint) DeletePromoCode(c *gin.Context) {
+	promoCodeId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.PromoCodes.DeletePromoCode(promoCodeId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Promo code deleted successfully",
+	})
+}
+
+func (e *Endpoint) ApplyPromoCode(c *gin.Context) {
+	var input ApplyPromoCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	application, err := e.services.PromoCodes.ApplyPromoCode(userId, input.Code)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"promo": application,
+	})
+}
@@ -0,0 +1,86 @@
+package endpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	retryAfterHeader = "Retry-After"
+	// rateLimitBodyLimit caps how much of an anonymous request body is read to
+	// find the account email. Auth request bodies are far smaller.
+	rateLimitBodyLimit = 64 << 10
+)
+
+// RateLimit limits requests of the route group per client IP and per account.
+// A limiter failure is logged and the request is let through, so an outage of
+// the store doesn't take the API down with it.
+func (e *Endpoint) RateLimit(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account, err := e.rateLimitAccount(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ErrorResponse{Message: err.Error()})
+			return
+		}
+		err = e.services.RateLimits.Allow(c, group, c.ClientIP(), account)
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, service.ErrRateLimited) {
+			logrus.Errorf("rate limiter error: %s", err.Error())
+			return
+		}
+		setRetryAfter(c, err)
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, ErrorResponse{Message: err.Error()})
+	}
+}
+
+// rateLimitAccount names the account a request acts on: the signed in user or,
+// for anonymous auth requests, the email in the JSON body. Only an oversized
+// body is an error, so the per-account limit can't be skipped by padding it.
+func (e *Endpoint) rateLimitAccount(c *gin.Context) (string, error) {
+	if userId, err := e.GetUserId(c); err == nil && userId != 0 {
+		return fmt.Sprintf("user:%d", userId), nil
+	}
+	if c.Request.Body == nil || c.ContentType() != gin.MIMEJSON {
+		return "", nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, rateLimitBodyLimit))
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return "", err
+		}
+		return "", nil
+	}
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &input); err != nil || input.Email == "" {
+		return "", nil
+	}
+	return "email:" + strings.ToLower(strings.TrimSpace(input.Email)), nil
+}
+
+func setRetryAfter(c *gin.Context, err error) {
+	var retryErr *service.RetryAfterError
+	if errors.As(err, &retryErr) {
+		seconds := int(math.Ceil(retryErr.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		c.Header(retryAfterHeader, strconv.Itoa(seconds))
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/service"
 )
 
 type CreateReviewInput struct {
@@ -59,9 +60,14 @@ func (e *Endpoint) DeleteReview(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
 		return
 	}
-	err = e.services.Reviews.DeleteReview(reviewId)
+	userId, err := e.GetUserId(c)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: err.Error()})
+		return
+	}
+	err = e.services.Reviews.DeleteReview(reviewId, userId, e.HasPermission(c, service.ReviewsModerate))
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"message": "Review deleted successfully"})
@@ -96,7 +102,7 @@ func (e *Endpoint) UpdateReview(c *gin.Context) {
 	}
 	err = e.services.Reviews.UpdateReview(reviewId, review)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{Message: err.Error()})
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{"message": "Review updated successfully"})
@@ -0,0 +1,66 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (e *Endpoint) Logout(c *gin.Context) {
+	var input RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.Auth.Logout(input.RefreshToken); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Logged out successfully"})
+}
+
+func (e *Endpoint) LogoutAll(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	if err := e.services.Auth.LogoutAll(userId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Logged out of all sessions successfully"})
+}
+
+func (e *Endpoint) GetSessions(c *gin.Context) {
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	sessions, err := e.services.Auth.GetSessions(userId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"sessions": sessions})
+}
+
+func (e *Endpoint) RevokeSession(c *gin.Context) {
+	sessionId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	userId, err := e.GetUserId(c)
+	if err != nil || userId == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Message: ErrNotAuthorized.Error()})
+		return
+	}
+	if err := e.services.Auth.RevokeSession(userId, sessionId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{"message": "Session revoked successfully"})
+}
@@ -0,0 +1,125 @@
+package endpoint
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/service"
+)
+
+type ShopPointInput struct {
+	Name         string   `json:"name" binding:"required" validate:"required,max=255"`
+	Address      string   `json:"address" binding:"required" validate:"required,max=255"`
+	WorkingHours string   `json:"working_hours" validate:"max=255"`
+	Latitude     *float64 `json:"latitude" validate:"omitempty,latitude"`
+	Longitude    *float64 `json:"longitude" validate:"omitempty,longitude"`
+	IsActive     *bool    `json:"is_active"`
+}
+
+func (i ShopPointInput) toModel() model.ShopPoint {
+	isActive := true
+	if i.IsActive != nil {
+		isActive = *i.IsActive
+	}
+	return model.ShopPoint{
+		Name:         i.Name,
+		Address:      i.Address,
+		WorkingHours: i.WorkingHours,
+		Latitude:     i.Latitude,
+		Longitude:    i.Longitude,
+		IsActive:     isActive,
+	}
+}
+
+func (e *Endpoint) CreateShopPoint(c *gin.Context) {
+	var input ShopPointInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	shopPointId, err := e.services.ShopPoints.CreateShopPoint(input.toModel())
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"shop_point_id": shopPointId,
+	})
+}
+
+func (e *Endpoint) GetShopPoints(c *gin.Context) {
+	includeInactive := c.Query("all") == "true"
+	if includeInactive && !e.HasPermission(c, service.ShopPointsWrite) {
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{Message: ErrForbidden.Error()})
+		return
+	}
+	shopPoints, err := e.services.ShopPoints.GetShopPoints(includeInactive)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"shop_points": shopPoints,
+	})
+}
+
+func (e *Endpoint) GetShopPoint(c *gin.Context) {
+	shopPointId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	shopPoint, err := e.services.ShopPoints.GetShopPoint(shopPointId)
+	if err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"shop_point": shopPoint,
+	})
+}
+
+func (e *Endpoint) UpdateShopPoint(c *gin.Context) {
+	shopPointId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	var input ShopPointInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.ShopPoints.UpdateShopPoint(shopPointId, input.toModel()); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Shop point updated successfully",
+	})
+}
+
+func (e *Endpoint) DeleteShopPoint(c *gin.Context) {
+	shopPointId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+		return
+	}
+	if err := e.services.ShopPoints.DeleteShopPoint(shopPointId); err != nil {
+		c.AbortWithStatusJSON(errorStatus(err), ErrorResponse{Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Shop point deleted successfully",
+	})
+}
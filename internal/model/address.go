@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type Address struct {
+	ID            int       `json:"id" db:"id"`
+	UserID        int       `json:"user_id" db:"user_id"`
+	RecipientName string    `json:"recipient_name" db:"recipient_name"`
+	Phone         string    `json:"phone" db:"phone"`
+	City          string    `json:"city" db:"city"`
+	Street        string    `json:"street" db:"street"`
+	PostalIndex   int       `json:"postal_index" db:"postal_index"`
+	IsDefault     bool      `json:"is_default" db:"is_default"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+type IdempotencyKey struct {
+	ID             int       `json:"id" db:"id"`
+	Scope          string    `json:"scope" db:"scope"`
+	Key            string    `json:"key" db:"key"`
+	Method         string    `json:"method" db:"method"`
+	Path           string    `json:"path" db:"path"`
+	RequestHash    string    `json:"request_hash" db:"request_hash"`
+	ResponseStatus *int      `json:"response_status" db:"response_status"`
+	ResponseBody   *string   `json:"response_body" db:"response_body"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
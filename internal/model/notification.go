@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type Notification struct {
+	ID            int        `json:"id" db:"id"`
+	UserID        int        `json:"user_id" db:"user_id"`
+	OrderID       *int       `json:"order_id" db:"order_id"`
+	Event         string     `json:"event" db:"event"`
+	FromStatus    *string    `json:"from_status" db:"from_status"`
+	ToStatus      *string    `json:"to_status" db:"to_status"`
+	Status        string     `json:"status" db:"status"`
+	Recipient     *string    `json:"recipient" db:"recipient"`
+	Subject       *string    `json:"subject" db:"subject"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     *string    `json:"last_error" db:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at" db:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+type NotificationsFilter struct {
+	OrderID int
+	Status  string
+	Page    int
+}
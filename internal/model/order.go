@@ -1,28 +1,65 @@
 package model
 
+import "time"
+
 type Order struct {
 	ID              int              `json:"id" db:"id"`
 	Type            string           `json:"type" db:"type"`
 	Status          string           `json:"status" db:"status"`
 	ShopPoint       string           `json:"shop_point" db:"shop_point"`
+	ShopPointID     *int             `json:"shop_point_id" db:"shop_point_id"`
 	UserID          int              `json:"user_id" db:"user_id"`
 	PaymentID       string           `json:"payment_id" db:"payment_id"`
 	OrderPrice      int              `json:"order_price" db:"order_price"`
-	DeliveryID      string           `json:"delivery_id" db:"delivery_id"`
-	PickupID        string           `json:"pickup_id" db:"pickup_id"`
+	PromoCodeID     *int             `json:"promo_code_id" db:"promo_code_id"`
+	PromoCode       *string          `json:"promo_code" db:"promo_code"`
+	DiscountAmount  int              `json:"discount_amount" db:"discount_amount"`
+	DeliveryID      *string          `json:"delivery_id" db:"delivery_id"`
+	PickupID        *string          `json:"pickup_id" db:"pickup_id"`
 	DeliveryPrice   int              `json:"delivery_price" db:"delivery_price"`
 	DeliveryAddress string           `json:"delivery_address" db:"delivery_address"`
 	DeliveryIndex   int              `json:"delivery_index" db:"delivery_index"`
+	AddressID       *int             `json:"address_id" db:"address_id"`
+	RecipientName   *string          `json:"recipient_name" db:"recipient_name"`
+	RecipientPhone  *string          `json:"recipient_phone" db:"recipient_phone"`
+	StockCommitted  bool             `json:"-" db:"stock_committed"`
+	CreatedAt       time.Time        `json:"created_at" db:"created_at"`
 	UserEmail       string           `json:"user_email" db:"user_email"`
+	UserName        string           `json:"user_name" db:"user_name"`
 	OrderedProducts []OrderedProduct `json:"ordered_products" db:"ordered_products"`
 }
 
 type OrderedProduct struct {
-	ID          int    `json:"id" db:"id"`
-	OrderID     int    `json:"order_id" db:"order_id"`
-	ProductID   int    `json:"product_id" db:"product_id"`
-	Size        string `json:"size" db:"size"`
-	Amount      int    `json:"amount" db:"amount"`
-	Price       int    `json:"price" db:"price"`
-	ProductName string `json:"product_name" db:"product_name"`
+	ID             int    `json:"id" db:"id"`
+	OrderID        int    `json:"order_id" db:"order_id"`
+	ProductID      int    `json:"product_id" db:"product_id"`
+	Size           string `json:"size" db:"size"`
+	Amount         int    `json:"amount" db:"amount"`
+	Price          int    `json:"price" db:"price"`
+	ProductName    string `json:"product_name" db:"product_name"`
+	ReturnedAmount int    `json:"returned_amount" db:"returned_amount"`
+	DiscountAmount int    `json:"discount_amount" db:"discount_amount"`
+}
+
+type ReturnedProduct struct {
+	OrderedProductID int `json:"ordered_product_id"`
+	Amount           int `json:"amount"`
+}
+
+type OrderStatusChange struct {
+	ID            int       `json:"id" db:"id"`
+	OrderID       int       `json:"order_id" db:"order_id"`
+	FromStatus    *string   `json:"from_status" db:"from_status"`
+	ToStatus      string    `json:"to_status" db:"to_status"`
+	ChangedBy     *int      `json:"changed_by" db:"changed_by"`
+	ChangedByName *string   `json:"changed_by_name" db:"changed_by_name"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+type OrdersFilter struct {
+	Status      string
+	ShopPointID int
+	From        *time.Time
+	To          *time.Time
+	Page        int
 }
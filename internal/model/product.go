@@ -7,9 +7,12 @@ type Product struct {
 	Name           string         `json:"name" db:"name"`
 	Description    string         `json:"description" db:"description"`
 	Price          int            `json:"price" db:"price"`
+	OriginalPrice  int            `json:"original_price" db:"original_price"`
+	OnSale         bool           `json:"on_sale" db:"on_sale"`
 	CollectionID   int            `json:"collection_id" db:"collection_id"`
 	Category       string         `json:"category" db:"category"`
 	Color          string         `json:"color" db:"color"`
+	Weight         int            `json:"weight" db:"weight"`
 	MainPhotoURL   *string        `json:"main_photo_url" db:"main_photo_url"`
 	CollectionName string         `json:"collection_name" db:"collection_name"`
 	IsLiked        bool           `json:"is_liked" db:"is_liked"`
@@ -39,15 +42,26 @@ type LikedProduct struct {
 }
 
 type ProductInCart struct {
-	ID           int     `json:"id" db:"id"`
-	ProductID    int     `json:"product_id" db:"product_id"`
-	ProductName  string  `json:"product_name" db:"product_name"`
-	UserID       int     `json:"user_id" db:"user_id"`
-	Size         string  `json:"size" db:"size"`
-	Amount       int     `json:"amount" db:"amount"`
-	Exists       bool    `json:"exists" db:"existence"`
-	MainPhotoURL *string `json:"main_photo_url" db:"main_photo_url"`
-	Price        int     `json:"price" db:"price"`
+	ID            int     `json:"id" db:"id"`
+	ProductID     int     `json:"product_id" db:"product_id"`
+	ProductName   string  `json:"product_name" db:"product_name"`
+	UserID        *int    `json:"user_id" db:"user_id"`
+	GuestCartID   *string `json:"-" db:"guest_cart_id"`
+	Size          string  `json:"size" db:"size"`
+	Amount        int     `json:"amount" db:"amount"`
+	Exists        bool    `json:"exists" db:"existence"`
+	MainPhotoURL  *string `json:"main_photo_url" db:"main_photo_url"`
+	Price         int     `json:"price" db:"price"`
+	OriginalPrice int     `json:"original_price" db:"original_price"`
+	Weight        int     `json:"weight" db:"weight"`
+	CollectionID  int     `json:"collection_id" db:"collection_id"`
+	Category      string  `json:"category" db:"category"`
+}
+
+// CartOwner identifies a cart: either a signed in user's or a guest's.
+type CartOwner struct {
+	UserID      int
+	GuestCartID string
 }
 
 type Category struct {
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type ProductPrice struct {
+	ID             int        `json:"id" db:"id"`
+	ProductID      int        `json:"product_id" db:"product_id"`
+	Price          int        `json:"price" db:"price"`
+	CompareAtPrice *int       `json:"compare_at_price" db:"compare_at_price"`
+	EffectiveFrom  time.Time  `json:"effective_from" db:"effective_from"`
+	EffectiveTo    *time.Time `json:"effective_to" db:"effective_to"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+type PromoCode struct {
+	ID                int        `json:"id" db:"id"`
+	Code              string     `json:"code" db:"code"`
+	DiscountType      string     `json:"discount_type" db:"discount_type"`
+	Value             int        `json:"value" db:"value"`
+	MinOrderSum       int        `json:"min_order_sum" db:"min_order_sum"`
+	ValidFrom         *time.Time `json:"valid_from" db:"valid_from"`
+	ValidTo           *time.Time `json:"valid_to" db:"valid_to"`
+	UsageLimit        *int       `json:"usage_limit" db:"usage_limit"`
+	UsageLimitPerUser *int       `json:"usage_limit_per_user" db:"usage_limit_per_user"`
+	CollectionID      *int       `json:"collection_id" db:"collection_id"`
+	Category          *string    `json:"category" db:"category"`
+	IsActive          bool       `json:"is_active" db:"is_active"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+}
+
+type PromoCodeApplication struct {
+	Code           string `json:"code"`
+	Subtotal       int    `json:"subtotal"`
+	DiscountAmount int    `json:"discount_amount"`
+	Total          int    `json:"total"`
+}
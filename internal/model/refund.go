@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+type Refund struct {
+	ID         int        `json:"id" db:"id"`
+	OrderID    int        `json:"order_id" db:"order_id"`
+	PaymentID  string     `json:"payment_id" db:"payment_id"`
+	Amount     int        `json:"amount" db:"amount"`
+	Reason     string     `json:"reason" db:"reason"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	RefundedAt *time.Time `json:"refunded_at" db:"refunded_at"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+type StockReservation struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	ProductID int       `json:"product_id" db:"product_id"`
+	Size      string    `json:"size" db:"size"`
+	Amount    int       `json:"amount" db:"amount"`
+	OrderID   *int      `json:"order_id" db:"order_id"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	FamilyID  string     `json:"family_id" db:"family_id"`
+	UserAgent string     `json:"user_agent" db:"user_agent"`
+	IP        string     `json:"ip" db:"ip"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type Session struct {
+	ID              int       `json:"id" db:"id"`
+	UserAgent       string    `json:"user_agent" db:"user_agent"`
+	IP              string    `json:"ip" db:"ip"`
+	StartedAt       time.Time `json:"started_at" db:"started_at"`
+	LastRefreshedAt time.Time `json:"last_refreshed_at" db:"last_refreshed_at"`
+	ExpiresAt       time.Time `json:"expires_at" db:"expires_at"`
+}
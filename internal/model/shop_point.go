@@ -0,0 +1,11 @@
+package model
+
+type ShopPoint struct {
+	ID           int      `json:"id" db:"id"`
+	Name         string   `json:"name" db:"name"`
+	Address      string   `json:"address" db:"address"`
+	WorkingHours string   `json:"working_hours" db:"working_hours"`
+	Latitude     *float64 `json:"latitude" db:"latitude"`
+	Longitude    *float64 `json:"longitude" db:"longitude"`
+	IsActive     bool     `json:"is_active" db:"is_active"`
+}
@@ -1,9 +1,34 @@
 package model
 
+import "time"
+
 type User struct {
-	ID       int    `json:"id" db:"id"`
-	Name     string `json:"name" db:"name"`
-	Email    string `json:"email" db:"email"`
-	Password string `json:"password" db:"password_hash"`
-	Role     string `json:"role" db:"role"`
+	ID                 int        `json:"id" db:"id"`
+	Name               string     `json:"name" db:"name"`
+	Email              string     `json:"email" db:"email"`
+	PendingEmail       *string    `json:"pending_email" db:"pending_email"`
+	Phone              *string    `json:"phone" db:"phone"`
+	Password           string     `json:"-" db:"password_hash"`
+	Role               string     `json:"role" db:"role"`
+	Locale             string     `json:"locale" db:"locale"`
+	EmailVerifiedAt    *time.Time `json:"email_verified_at" db:"email_verified_at"`
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at" db:"totp_enabled_at"`
+	DeletedAt          *time.Time `json:"-" db:"deleted_at"`
+	TokenVersion       int        `json:"-" db:"token_version"`
+}
+
+// TwoFactor is the TOTP state of a user. LastStep is the last accepted time
+// step, so a code can't be used twice.
+type TwoFactor struct {
+	Secret    *string    `db:"totp_secret"`
+	EnabledAt *time.Time `db:"totp_enabled_at"`
+	LastStep  *int64     `db:"totp_last_step"`
+}
+
+// UserUpdate holds the profile fields to change; nil fields are left as is
+// and an empty phone clears it.
+type UserUpdate struct {
+	Name   *string
+	Phone  *string
+	Locale *string
 }
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const userAddressesTable = "user_addresses"
+
+type AddressesPostgres struct {
+	db *sqlx.DB
+}
+
+func NewAddressesPostgres(db *sqlx.DB) *AddressesPostgres {
+	return &AddressesPostgres{db: db}
+}
+
+// CreateAddress makes the user's first address the default one.
+func (r *AddressesPostgres) CreateAddress(address model.Address) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	if address.IsDefault {
+		if err := clearDefaultAddress(tx, address.UserID); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	var id int
+	query := fmt.Sprintf(`INSERT INTO %[1]s (user_id, recipient_name, phone, city, street, postal_index, is_default)
+		VALUES ($1, $2, $3, $4, $5, $6, $7 OR NOT EXISTS (SELECT 1 FROM %[1]s WHERE user_id = $1)) RETURNING id`, userAddressesTable)
+	row := tx.QueryRow(query, address.UserID, address.RecipientName, address.Phone, address.City, address.Street, address.PostalIndex, address.IsDefault)
+	if err := row.Scan(&id); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *AddressesPostgres) GetAddresses(userId int) ([]model.Address, error) {
+	addresses := make([]model.Address, 0)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE user_id = $1 ORDER BY is_default DESC, id", userAddressesTable)
+	if err := r.db.Select(&addresses, query, userId); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (r *AddressesPostgres) GetAddress(userId int, addressId int) (model.Address, error) {
+	var address model.Address
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1 AND user_id = $2", userAddressesTable)
+	if err := r.db.Get(&address, query, addressId, userId); err != nil {
+		return model.Address{}, err
+	}
+	return address, nil
+}
+
+func (r *AddressesPostgres) UpdateAddress(userId int, addressId int, address model.Address) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	if address.IsDefault {
+		if err := clearDefaultAddress(tx, userId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	query := fmt.Sprintf("UPDATE %s SET recipient_name = $1, phone = $2, city = $3, street = $4, postal_index = $5, is_default = is_default OR $6 WHERE id = $7 AND user_id = $8", userAddressesTable)
+	result, err := tx.Exec(query, address.RecipientName, address.Phone, address.City, address.Street, address.PostalIndex, address.IsDefault, addressId, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkRowsAffected(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAddress removes the address; orders keep their own copy of it. When
+// the default address is removed the most recent remaining one takes over.
+func (r *AddressesPostgres) DeleteAddress(userId int, addressId int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	var wasDefault bool
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2 RETURNING is_default", userAddressesTable)
+	if err := tx.QueryRow(query, addressId, userId).Scan(&wasDefault); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if wasDefault {
+		query = fmt.Sprintf("UPDATE %[1]s SET is_default = TRUE WHERE id = (SELECT id FROM %[1]s WHERE user_id = $1 ORDER BY id DESC LIMIT 1)", userAddressesTable)
+		if _, err := tx.Exec(query, userId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func clearDefaultAddress(tx *sql.Tx, userId int) error {
+	query := fmt.Sprintf("UPDATE %s SET is_default = FALSE WHERE user_id = $1 AND is_default", userAddressesTable)
+	_, err := tx.Exec(query, userId)
+	return err
+}
@@ -1,12 +1,20 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lib/pq"
 )
 
+const deletedUserName = "Удалённый пользователь"
+
+var ErrEmailTaken = errors.New("email is already taken")
+
 type AuthPostgres struct {
 	db *sqlx.DB
 }
@@ -18,43 +26,55 @@ func NewAuthPostgres(db *sqlx.DB) *AuthPostgres {
 }
 
 func (r *AuthPostgres) CreateAdmin(name string, email string, password string) error {
-	query := fmt.Sprintf("INSERT INTO %s (name, email, password_hash, role) VALUES ($1, $2, $3, $4)", usersTable)
+	query := fmt.Sprintf("INSERT INTO %s (name, email, password_hash, role, email_verified_at) VALUES ($1, $2, $3, $4, now())", usersTable)
 	_, err := r.db.Exec(query, name, email, password, adminRole)
 	return err
 }
 
 func (r *AuthPostgres) CreateUser(user model.User) (int, error) {
 	var userId int
-	query := fmt.Sprintf("INSERT INTO %s (name, email, password_hash, role) VALUES ($1, $2, $3, $4) RETURNING id", usersTable)
-	row := r.db.QueryRow(query, user.Name, user.Email, user.Password, customerRole)
+	query := fmt.Sprintf("INSERT INTO %s (name, email, password_hash, role, locale) VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'ru')) RETURNING id", usersTable)
+	row := r.db.QueryRow(query, user.Name, user.Email, user.Password, customerRole, user.Locale)
 
 	if err := row.Scan(&userId); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return 0, ErrEmailTaken
+		}
 		return 0, err
 	}
 
 	return userId, nil
 }
 
-func (r *AuthPostgres) SignIn(email, password_hash string) (int, error) {
-	var userId int
-	query := fmt.Sprintf("SELECT id FROM %s WHERE email = $1 AND password_hash = $2", usersTable)
-	row := r.db.QueryRow(query, email, password_hash)
-
-	if err := row.Scan(&userId); err != nil {
-		return 0, err
+func (r *AuthPostgres) GetUserByEmail(email string) (model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT id, name, email, pending_email, phone, password_hash, role, locale, email_verified_at, totp_enabled_at, deleted_at, token_version FROM %s WHERE email = $1 AND deleted_at IS NULL", usersTable)
+	if err := r.db.Get(&user, query, email); err != nil {
+		return model.User{}, err
 	}
+	return user, nil
+}
 
-	return userId, nil
+func (r *AuthPostgres) UpdatePasswordHash(userId int, passwordHash string) error {
+	query := fmt.Sprintf("UPDATE %s SET password_hash = $1 WHERE id = $2", usersTable)
+	_, err := r.db.Exec(query, passwordHash, userId)
+	return err
+}
+
+func (r *AuthPostgres) MarkEmailVerified(userId int) error {
+	query := fmt.Sprintf("UPDATE %s SET email_verified_at = now() WHERE id = $1 AND email_verified_at IS NULL", usersTable)
+	_, err := r.db.Exec(query, userId)
+	return err
 }
 
 func (r *AuthPostgres) NewAdmin(thisAdminId int, newAdminId int) error {
-	query := fmt.Sprintf("UPDATE %s SET role = $1 WHERE id = $2", usersTable)
+	query := fmt.Sprintf("UPDATE %s SET role = $1, token_version = token_version + 1 WHERE id = $2", usersTable)
 	_, err := r.db.Exec(query, adminRole, newAdminId)
 	return err
 }
 
 func (r *AuthPostgres) NewBuyer(thisAdminId int, newBuyerId int) error {
-	query := fmt.Sprintf("UPDATE %s SET role = $1 WHERE id = $2", usersTable)
+	query := fmt.Sprintf("UPDATE %s SET role = $1, token_version = token_version + 1 WHERE id = $2", usersTable)
 	_, err := r.db.Exec(query, buyerRole, newBuyerId)
 	return err
 }
@@ -95,16 +115,124 @@ func (r *AuthPostgres) GetUserRole(userId int) (string, error) {
 
 func (r *AuthPostgres) GetUser(userId int) (model.User, error) {
 	var user model.User
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1", usersTable)
-	row := r.db.QueryRow(query, userId)
-	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role); err != nil {
+	query := fmt.Sprintf("SELECT id, name, email, pending_email, phone, password_hash, role, locale, email_verified_at, totp_enabled_at, deleted_at, token_version FROM %s WHERE id = $1", usersTable)
+	if err := r.db.Get(&user, query, userId); err != nil {
 		return model.User{}, err
 	}
 	return user, nil
 }
 
+func (r *AuthPostgres) GetTokenVersion(userId int) (int, error) {
+	var version int
+	query := fmt.Sprintf("SELECT token_version FROM %s WHERE id = $1", usersTable)
+	if err := r.db.Get(&version, query, userId); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (r *AuthPostgres) BumpTokenVersion(userId int) error {
+	query := fmt.Sprintf("UPDATE %s SET token_version = token_version + 1 WHERE id = $1", usersTable)
+	_, err := r.db.Exec(query, userId)
+	return err
+}
+
 func (r *AuthPostgres) RemoveBuyer(thisAdminId int, buyerId int) error {
-	query := fmt.Sprintf("UPDATE %s SET role = $1 WHERE id = $2", usersTable)
+	query := fmt.Sprintf("UPDATE %s SET role = $1, token_version = token_version + 1 WHERE id = $2", usersTable)
 	_, err := r.db.Exec(query, customerRole, buyerId)
 	return err
 }
+
+func (r *AuthPostgres) UpdateUser(userId int, update model.UserUpdate) error {
+	var set []string
+	var args []interface{}
+	if update.Name != nil {
+		args = append(args, *update.Name)
+		set = append(set, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if update.Phone != nil {
+		args = append(args, *update.Phone)
+		set = append(set, fmt.Sprintf("phone = NULLIF($%d, '')", len(args)))
+	}
+	if update.Locale != nil {
+		args = append(args, *update.Locale)
+		set = append(set, fmt.Sprintf("locale = $%d", len(args)))
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	args = append(args, userId)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d", usersTable, strings.Join(set, ", "), len(args))
+	_, err := r.db.Exec(query, args...)
+	return err
+}
+
+func (r *AuthPostgres) SetPendingEmail(userId int, email string) error {
+	query := fmt.Sprintf("UPDATE %s SET pending_email = $1 WHERE id = $2", usersTable)
+	_, err := r.db.Exec(query, email, userId)
+	return err
+}
+
+// ConfirmPendingEmail makes the pending email the user's email. It returns
+// sql.ErrNoRows when there is nothing to confirm.
+func (r *AuthPostgres) ConfirmPendingEmail(userId int) error {
+	query := fmt.Sprintf("UPDATE %s SET email = pending_email, pending_email = NULL, email_verified_at = now() WHERE id = $1 AND pending_email IS NOT NULL", usersTable)
+	result, err := r.db.Exec(query, userId)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUser anonymizes the user instead of deleting the row, so orders stay
+// for accounting and reviews remain under a placeholder name. Cart, liked
+// products, sessions and pending notifications are removed.
+func (r *AuthPostgres) DeleteUser(userId int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`UPDATE %s SET name = $1, email = 'deleted-' || id || '@deleted.invalid', pending_email = NULL, phone = NULL,
+		password_hash = '', role = $2, email_verified_at = NULL,
+		totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL, deleted_at = now(), token_version = token_version + 1 WHERE id = $3 AND deleted_at IS NULL`, usersTable)
+	result, err := tx.Exec(query, deletedUserName, customerRole, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+	for _, table := range []string{productsInCartTable, likedProductsTable, userTokensTable, refreshTokensTable, recoveryCodesTable, identitiesTable, userAddressesTable} {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", table), userId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := releaseUserReservations(tx, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	query = fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND status = $2", notificationsTable)
+	if _, err := tx.Exec(query, userId, notificationPendingStatus); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
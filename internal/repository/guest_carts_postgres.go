@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const guestCartsTable = "guest_carts"
+
+type GuestCartsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewGuestCartsPostgres(db *sqlx.DB) *GuestCartsPostgres {
+	return &GuestCartsPostgres{db: db}
+}
+
+// MergeGuestCart moves the guest's cart lines to the user and deletes the
+// guest cart. Amounts of the same product and size are summed, capped by the
+// stock of the size, but never below what the user already had.
+func (r *GuestCartsPostgres) MergeGuestCart(guestCartId string, userId int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`UPDATE %[1]s u SET amount = GREATEST(u.amount, LEAST(u.amount + g.amount, COALESCE(s.amount, u.amount + g.amount)))
+		FROM %[1]s g LEFT JOIN %[2]s s ON s.product_id = g.product_id AND s.name = g.size
+		WHERE g.guest_cart_id = $1 AND u.user_id = $2 AND u.product_id = g.product_id AND u.size = g.size`, productsInCartTable, sizesTable)
+	if _, err := tx.Exec(query, guestCartId, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	query = fmt.Sprintf(`INSERT INTO %[1]s (user_id, product_id, size, amount, existence)
+		SELECT $2, g.product_id, g.size, LEAST(g.amount, COALESCE(s.amount, g.amount)), g.existence
+		FROM %[1]s g LEFT JOIN %[2]s s ON s.product_id = g.product_id AND s.name = g.size
+		WHERE g.guest_cart_id = $1 AND LEAST(g.amount, COALESCE(s.amount, g.amount)) > 0
+			AND NOT EXISTS (SELECT 1 FROM %[1]s u WHERE u.user_id = $2 AND u.product_id = g.product_id AND u.size = g.size)
+		ON CONFLICT (user_id, product_id, size) WHERE user_id IS NOT NULL DO NOTHING`, productsInCartTable, sizesTable)
+	if _, err := tx.Exec(query, guestCartId, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	query = fmt.Sprintf("DELETE FROM %s WHERE id = $1", guestCartsTable)
+	if _, err := tx.Exec(query, guestCartId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *GuestCartsPostgres) DeleteExpiredGuestCarts(updatedBefore time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE updated_at < $1", guestCartsTable)
+	result, err := r.db.Exec(query, updatedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
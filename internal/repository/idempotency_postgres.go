@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const idempotencyKeysTable = "idempotency_keys"
+
+type IdempotencyPostgres struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyPostgres(db *sqlx.DB) *IdempotencyPostgres {
+	return &IdempotencyPostgres{db: db}
+}
+
+func (r *IdempotencyPostgres) CreateIdempotencyKey(key model.IdempotencyKey) (model.IdempotencyKey, bool, error) {
+	query := fmt.Sprintf("INSERT INTO %s (scope, key, method, path, request_hash) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (scope, key) DO NOTHING RETURNING id, created_at", idempotencyKeysTable)
+	err := r.db.QueryRow(query, key.Scope, key.Key, key.Method, key.Path, key.RequestHash).Scan(&key.ID, &key.CreatedAt)
+	if err == nil {
+		return key, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return model.IdempotencyKey{}, false, err
+	}
+	var existing model.IdempotencyKey
+	query = fmt.Sprintf("SELECT * FROM %s WHERE scope = $1 AND key = $2", idempotencyKeysTable)
+	if err := r.db.Get(&existing, query, key.Scope, key.Key); err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+	return existing, false, nil
+}
+
+func (r *IdempotencyPostgres) SaveIdempotencyResponse(id int, status int, body string) error {
+	query := fmt.Sprintf("UPDATE %s SET response_status = $1, response_body = $2 WHERE id = $3", idempotencyKeysTable)
+	_, err := r.db.Exec(query, status, body, id)
+	return err
+}
+
+func (r *IdempotencyPostgres) DeleteIdempotencyKey(id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", idempotencyKeysTable)
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *IdempotencyPostgres) DeleteIdempotencyKeysBefore(createdBefore time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE created_at < $1", idempotencyKeysTable)
+	result, err := r.db.Exec(query, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lib/pq"
+)
+
+const identitiesTable = "identities"
+
+var ErrIdentityExists = errors.New("this external account is already linked")
+
+type IdentitiesPostgres struct {
+	db *sqlx.DB
+}
+
+func NewIdentitiesPostgres(db *sqlx.DB) *IdentitiesPostgres {
+	return &IdentitiesPostgres{db: db}
+}
+
+func (r *IdentitiesPostgres) GetIdentity(provider string, subject string) (model.Identity, error) {
+	var identity model.Identity
+	query := fmt.Sprintf(`SELECT i.* FROM %s i JOIN %s u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND u.deleted_at IS NULL`, identitiesTable, usersTable)
+	if err := r.db.Get(&identity, query, provider, subject); err != nil {
+		return model.Identity{}, err
+	}
+	return identity, nil
+}
+
+func (r *IdentitiesPostgres) GetUserIdentities(userId int) ([]model.Identity, error) {
+	identities := make([]model.Identity, 0)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE user_id = $1 ORDER BY id", identitiesTable)
+	if err := r.db.Select(&identities, query, userId); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// LinkIdentity links the external account to an existing user. The provider
+// has confirmed the email, so the user's email counts as verified too.
+func (r *IdentitiesPostgres) LinkIdentity(identity model.Identity) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)", identitiesTable)
+	if _, err := tx.Exec(query, identity.UserID, identity.Provider, identity.Subject, identity.Email); err != nil {
+		tx.Rollback()
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return ErrIdentityExists
+		}
+		return err
+	}
+	query = fmt.Sprintf("UPDATE %s SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1", usersTable)
+	if _, err := tx.Exec(query, identity.UserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateUserWithIdentity signs up a user who has no password. Their email
+// counts as verified only if the provider said so.
+func (r *IdentitiesPostgres) CreateUserWithIdentity(user model.User, identity model.Identity, emailVerified bool) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	var userId int
+	query := fmt.Sprintf(`INSERT INTO %s (name, email, password_hash, role, locale, email_verified_at)
+		VALUES ($1, $2, '', $3, COALESCE(NULLIF($4, ''), 'ru'), CASE WHEN $5 THEN now() END) RETURNING id`, usersTable)
+	if err := tx.QueryRow(query, user.Name, user.Email, customerRole, user.Locale, emailVerified).Scan(&userId); err != nil {
+		tx.Rollback()
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return 0, ErrEmailTaken
+		}
+		return 0, err
+	}
+	query = fmt.Sprintf("INSERT INTO %s (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)", identitiesTable)
+	if _, err := tx.Exec(query, userId, identity.Provider, identity.Subject, identity.Email); err != nil {
+		tx.Rollback()
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return 0, ErrIdentityExists
+		}
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const (
+	notificationsTable = "notifications"
+
+	orderCreatedEvent       = "order_created"
+	orderStatusChangedEvent = "order_status_changed"
+
+	notificationPendingStatus = "pending"
+	notificationSentStatus    = "sent"
+	notificationsPageLimit    = 50
+)
+
+type NotificationsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewNotificationsPostgres(db *sqlx.DB) *NotificationsPostgres {
+	return &NotificationsPostgres{db: db}
+}
+
+// enqueueOrderNotification is called in the same transaction that records an
+// order status change, so a notification exists for every committed change.
+func enqueueOrderNotification(tx *sql.Tx, orderId int, fromStatus string, status string) error {
+	event := orderStatusChangedEvent
+	if fromStatus == "" {
+		event = orderCreatedEvent
+	}
+	query := fmt.Sprintf("INSERT INTO %s (user_id, order_id, event, from_status, to_status) SELECT o.user_id, o.id, $2, NULLIF($3, ''), $4 FROM %s o JOIN %s u ON u.id = o.user_id WHERE o.id = $1 AND u.deleted_at IS NULL", notificationsTable, ordersTable, usersTable)
+	_, err := tx.Exec(query, orderId, event, fromStatus, status)
+	return err
+}
+
+// ClaimPendingNotifications picks due notifications and postpones them until
+// leaseUntil, so concurrent workers don't send the same email twice.
+func (r *NotificationsPostgres) ClaimPendingNotifications(limit int, leaseUntil time.Time) ([]model.Notification, error) {
+	notifications := make([]model.Notification, 0)
+	query := fmt.Sprintf(`UPDATE %[1]s SET next_attempt_at = $3 WHERE id IN (
+		SELECT id FROM %[1]s WHERE status = $1 AND next_attempt_at <= now() ORDER BY next_attempt_at, id LIMIT $2 FOR UPDATE SKIP LOCKED
+	) RETURNING *`, notificationsTable)
+	if err := r.db.Select(&notifications, query, notificationPendingStatus, limit, leaseUntil); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *NotificationsPostgres) MarkNotificationSent(notificationId int, recipient string, subject string) error {
+	query := fmt.Sprintf("UPDATE %s SET status = $2, recipient = $3, subject = $4, attempts = attempts + 1, last_error = NULL, sent_at = now() WHERE id = $1", notificationsTable)
+	_, err := r.db.Exec(query, notificationId, notificationSentStatus, recipient, subject)
+	return err
+}
+
+func (r *NotificationsPostgres) MarkNotificationFailed(notificationId int, status string, lastError string, nextAttemptAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4 WHERE id = $1", notificationsTable)
+	_, err := r.db.Exec(query, notificationId, status, lastError, nextAttemptAt)
+	return err
+}
+
+func (r *NotificationsPostgres) ResetNotification(notificationId int) error {
+	query := fmt.Sprintf("UPDATE %s SET status = $2, attempts = 0, last_error = NULL, next_attempt_at = now() WHERE id = $1", notificationsTable)
+	result, err := r.db.Exec(query, notificationId, notificationPendingStatus)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *NotificationsPostgres) GetNotifications(filter model.NotificationsFilter) ([]model.Notification, error) {
+	notifications := make([]model.Notification, 0)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE TRUE", notificationsTable)
+	args := []interface{}{}
+	if filter.OrderID != 0 {
+		args = append(args, filter.OrderID)
+		query += fmt.Sprintf(" AND order_id = $%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT %d OFFSET %d", notificationsPageLimit, (filter.Page-1)*notificationsPageLimit)
+	if err := r.db.Select(&notifications, query, args...); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
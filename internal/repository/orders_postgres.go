@@ -2,7 +2,9 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lavatee/dresscode_backend/internal/model"
@@ -17,6 +19,16 @@ const (
 	issuedStatus              = "issued"
 	sentToCustomerStatus      = "sent_to_customer"
 	deliveredToCustomerStatus = "delivered_to_customer"
+	cancelledStatus           = "cancelled"
+	returnedStatus            = "returned"
+	ordersPageLimit           = 20
+)
+
+var (
+	ErrNoOrderedProducts   = errors.New("order has no products")
+	ErrNotEnoughStock      = errors.New("not enough size on the warehouse or size not found")
+	ErrOrderStatusChanged  = errors.New("order status was changed concurrently")
+	ErrInvalidReturnAmount = errors.New("returned amount exceeds ordered amount or product is not in the order")
 )
 
 type OrdersPostgres struct {
@@ -29,21 +41,50 @@ func NewOrdersPostgres(db *sqlx.DB) *OrdersPostgres {
 	}
 }
 
-func (r *OrdersPostgres) CreateOrder(order model.Order) (model.Order, error) {
+func (r *OrdersPostgres) CreateOrder(order model.Order, reservationExpiresAt time.Time) (model.Order, error) {
+	sizes, err := orderedProductsSizes(order.OrderedProducts)
+	if err != nil {
+		return model.Order{}, err
+	}
 	tx, err := r.db.Begin()
 	if err != nil {
 		return model.Order{}, err
 	}
-	query := fmt.Sprintf("INSERT INTO %s (user_id, status, type, shop_point, payment_id, order_price, delivery_address, delivery_index, delivery_price) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id", ordersTable)
-	row := tx.QueryRow(query, order.UserID, order.Status, order.Type, order.ShopPoint, order.PaymentID, order.OrderPrice, order.DeliveryAddress, order.DeliveryIndex, order.DeliveryPrice)
-	if err := row.Scan(&order.ID); err != nil {
+	query := fmt.Sprintf("INSERT INTO %s (user_id, status, type, shop_point, shop_point_id, payment_id, order_price, delivery_address, delivery_index, delivery_price, promo_code_id, promo_code, discount_amount, address_id, recipient_name, recipient_phone, stock_committed) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, FALSE) RETURNING id, created_at", ordersTable)
+	row := tx.QueryRow(query, order.UserID, order.Status, order.Type, order.ShopPoint, order.ShopPointID, order.PaymentID, order.OrderPrice, order.DeliveryAddress, order.DeliveryIndex, order.DeliveryPrice, order.PromoCodeID, order.PromoCode, order.DiscountAmount, order.AddressID, order.RecipientName, order.RecipientPhone)
+	if err := row.Scan(&order.ID, &order.CreatedAt); err != nil {
 		tx.Rollback()
 		return model.Order{}, err
 	}
+	for i := range order.OrderedProducts {
+		order.OrderedProducts[i].OrderID = order.ID
+	}
 	if err := r.CreateOrderedProducts(tx, order.OrderedProducts); err != nil {
 		tx.Rollback()
 		return model.Order{}, err
 	}
+	if order.PromoCodeID != nil {
+		if err := usePromoCode(tx, *order.PromoCodeID, order.UserID, order.ID); err != nil {
+			tx.Rollback()
+			return model.Order{}, err
+		}
+	}
+	if err := releaseUserReservations(tx, order.UserID); err != nil {
+		tx.Rollback()
+		return model.Order{}, err
+	}
+	if err := reserveStock(tx, order.UserID, order.ID, sizes, reservationExpiresAt); err != nil {
+		tx.Rollback()
+		return model.Order{}, err
+	}
+	if err := r.addStatusHistory(tx, order.ID, "", order.Status, order.UserID); err != nil {
+		tx.Rollback()
+		return model.Order{}, err
+	}
+	if err := r.ClearCart(tx, order.UserID); err != nil {
+		tx.Rollback()
+		return model.Order{}, err
+	}
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		return model.Order{}, err
@@ -51,31 +92,71 @@ func (r *OrdersPostgres) CreateOrder(order model.Order) (model.Order, error) {
 	return order, nil
 }
 
-func (r *OrdersPostgres) CreateOrderedProducts(tx *sql.Tx, orderedProducts []model.OrderedProduct) error {
-	query := fmt.Sprintf("INSERT INTO %s (order_id, product_id, size, amount, price, product_name) VALUES ", orderedProductsTable)
-	argsCounter := 0
-	args := make([]interface{}, 0)
-	sizes := make([]SizeInfo, 0)
+func orderedProductsSizes(orderedProducts []model.OrderedProduct) ([]SizeInfo, error) {
+	if len(orderedProducts) == 0 {
+		return nil, ErrNoOrderedProducts
+	}
+	sizes := make([]SizeInfo, 0, len(orderedProducts))
 	sizesMap := make(map[string]bool)
 	for _, orderedProduct := range orderedProducts {
-		query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d),", argsCounter+1, argsCounter+2, argsCounter+3, argsCounter+4, argsCounter+5, argsCounter+6)
-		args = append(args, orderedProduct.OrderID, orderedProduct.ProductID, orderedProduct.Size, orderedProduct.Amount, orderedProduct.Price, orderedProduct.ProductName)
-		argsCounter += 6
-		if _, exists := sizesMap[fmt.Sprintf("%d_%s", orderedProduct.ProductID, orderedProduct.Size)]; exists {
-			return fmt.Errorf("2 sizes with the same product_id and size_name")
+		key := fmt.Sprintf("%d_%s", orderedProduct.ProductID, orderedProduct.Size)
+		if sizesMap[key] {
+			return nil, fmt.Errorf("2 sizes with the same product_id and size_name")
 		}
+		sizesMap[key] = true
 		sizes = append(sizes, SizeInfo{ProductID: orderedProduct.ProductID, SizeName: orderedProduct.Size, Amount: orderedProduct.Amount})
-		sizesMap[fmt.Sprintf("%d_%s", orderedProduct.ProductID, orderedProduct.Size)] = true
+	}
+	return sizes, nil
+}
+
+func (r *OrdersPostgres) CreateOrderedProducts(tx *sql.Tx, orderedProducts []model.OrderedProduct) error {
+	if len(orderedProducts) == 0 {
+		return ErrNoOrderedProducts
+	}
+	query := fmt.Sprintf("INSERT INTO %s (order_id, product_id, size, amount, price, product_name, discount_amount) VALUES ", orderedProductsTable)
+	argsCounter := 0
+	args := make([]interface{}, 0)
+	for _, orderedProduct := range orderedProducts {
+		query += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d),", argsCounter+1, argsCounter+2, argsCounter+3, argsCounter+4, argsCounter+5, argsCounter+6, argsCounter+7)
+		args = append(args, orderedProduct.OrderID, orderedProduct.ProductID, orderedProduct.Size, orderedProduct.Amount, orderedProduct.Price, orderedProduct.ProductName, orderedProduct.DiscountAmount)
+		argsCounter += 7
 	}
 	query = query[:len(query)-1]
 	_, err := tx.Exec(query, args...)
+	return err
+}
+
+func (r *OrdersPostgres) commitOrderStock(tx *sql.Tx, orderId int) error {
+	var id int
+	query := fmt.Sprintf("UPDATE %s SET stock_committed = TRUE WHERE id = $1 AND NOT stock_committed RETURNING id", ordersTable)
+	if err := tx.QueryRow(query, orderId).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	query = fmt.Sprintf("SELECT product_id, size, amount FROM %s WHERE order_id = $1", orderedProductsTable)
+	rows, err := tx.Query(query, orderId)
 	if err != nil {
 		return err
 	}
+	sizes := make([]SizeInfo, 0)
+	for rows.Next() {
+		var size SizeInfo
+		if err := rows.Scan(&size.ProductID, &size.SizeName, &size.Amount); err != nil {
+			rows.Close()
+			return err
+		}
+		sizes = append(sizes, size)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 	if err := r.DecreaseProductSizeAmount(tx, sizes); err != nil {
 		return err
 	}
-	return nil
+	return releaseOrderReservations(tx, orderId)
 }
 
 type SizeInfo struct {
@@ -87,18 +168,20 @@ type SizeInfo struct {
 func (r *OrdersPostgres) DecreaseProductSizeAmount(tx *sql.Tx, sizes []SizeInfo) error {
 	query := fmt.Sprintf("UPDATE %s SET amount = amount - CASE", sizesTable)
 	whereConditions := ""
+	args := make([]interface{}, 0, len(sizes)*3)
+	argIdx := 1
 	for _, size := range sizes {
-		query += fmt.Sprintf(" WHEN product_id = %d AND name = '%s' AND amount >= %d THEN %d",
-			size.ProductID, size.SizeName, size.Amount, size.Amount)
+		query += fmt.Sprintf(" WHEN product_id = $%d AND name = $%d THEN $%d::bigint", argIdx, argIdx+1, argIdx+2)
 		if whereConditions != "" {
 			whereConditions += " OR "
 		}
-		whereConditions += fmt.Sprintf("(product_id = %d AND name = '%s')",
-			size.ProductID, size.SizeName)
+		whereConditions += fmt.Sprintf("(product_id = $%d AND name = $%d AND amount >= $%d)", argIdx, argIdx+1, argIdx+2)
+		args = append(args, size.ProductID, size.SizeName, size.Amount)
+		argIdx += 3
 	}
 	query += " ELSE 0 END WHERE " + whereConditions
 
-	result, err := tx.Exec(query)
+	result, err := tx.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -109,24 +192,31 @@ func (r *OrdersPostgres) DecreaseProductSizeAmount(tx *sql.Tx, sizes []SizeInfo)
 	}
 
 	if rowsAffected != int64(len(sizes)) {
-		return fmt.Errorf("not enough size on the warehouse or size not found")
+		return ErrNotEnoughStock
 	}
 
 	return nil
 }
 
+func (r *OrdersPostgres) ClearCart(tx *sql.Tx, userId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", productsInCartTable)
+	_, err := tx.Exec(query, userId)
+	return err
+}
+
 func (r *OrdersPostgres) GetUserOrders(userId int, status string, orderType string) ([]model.Order, error) {
-	var orders []model.Order
+	orders := make([]model.Order, 0)
 	query := fmt.Sprintf("SELECT * FROM %s WHERE user_id = $1", ordersTable)
 	args := []interface{}{userId}
 	if status != "" {
-		query += " AND status = $2"
 		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
 	}
 	if orderType != "" {
-		query += " AND type = $3"
 		args = append(args, orderType)
+		query += fmt.Sprintf(" AND type = $%d", len(args))
 	}
+	query += " ORDER BY created_at DESC"
 	if err := r.db.Select(&orders, query, args...); err != nil {
 		return nil, err
 	}
@@ -146,33 +236,214 @@ func (r *OrdersPostgres) GetOrder(orderId int) (model.Order, error) {
 	return order, nil
 }
 
-func (r *OrdersPostgres) GetDeliveryOrders(status string) ([]model.Order, error) {
-	var orders []model.Order
-	query := fmt.Sprintf("SELECT o.*, u.email as user_email, u.name as user_name FROM %s o LEFT JOIN %s u ON o.user_id = u.id WHERE status = $1 AND type = $2", ordersTable, usersTable)
-	if err := r.db.Select(&orders, query, status, deliveryType); err != nil {
+func (r *OrdersPostgres) GetDeliveryOrders(filter model.OrdersFilter) ([]model.Order, error) {
+	filter.ShopPointID = 0
+	return r.getOrdersByType(deliveryType, filter)
+}
+
+func (r *OrdersPostgres) GetPickupOrders(filter model.OrdersFilter) ([]model.Order, error) {
+	return r.getOrdersByType(pickupType, filter)
+}
+
+func (r *OrdersPostgres) getOrdersByType(orderType string, filter model.OrdersFilter) ([]model.Order, error) {
+	orders := make([]model.Order, 0)
+	query := fmt.Sprintf("SELECT o.*, u.email as user_email, u.name as user_name FROM %s o LEFT JOIN %s u ON o.user_id = u.id WHERE o.type = $1", ordersTable, usersTable)
+	args := []interface{}{orderType}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		query += fmt.Sprintf(" AND o.status = $%d", len(args))
+	}
+	if filter.ShopPointID != 0 {
+		args = append(args, filter.ShopPointID)
+		query += fmt.Sprintf(" AND o.shop_point_id = $%d", len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += fmt.Sprintf(" AND o.created_at >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += fmt.Sprintf(" AND o.created_at < $%d", len(args))
+	}
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	query += fmt.Sprintf(" ORDER BY o.created_at DESC, o.id DESC LIMIT %d OFFSET %d", ordersPageLimit, (filter.Page-1)*ordersPageLimit)
+	if err := r.db.Select(&orders, query, args...); err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
 
-func (r *OrdersPostgres) GetPickupOrders(status string) ([]model.Order, error) {
-	var orders []model.Order
-	query := fmt.Sprintf("SELECT o.*, u.email as user_email, u.name as user_name FROM %s o LEFT JOIN %s u ON o.user_id = u.id WHERE status = $1 AND type = $2", ordersTable, usersTable)
-	if err := r.db.Select(&orders, query, status, pickupType); err != nil {
-		return nil, err
+func (r *OrdersPostgres) SetOrderStatus(orderId int, fromStatus string, status string, changedBy int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
-	return orders, nil
+	if err := r.updateOrderStatus(tx, orderId, fromStatus, status); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if fromStatus == pendingStatus {
+		if err := r.commitOrderStock(tx, orderId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := r.addStatusHistory(tx, orderId, fromStatus, status, changedBy); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *OrdersPostgres) SetOrderStatus(orderId int, status string) error {
-	query := fmt.Sprintf("UPDATE %s SET status = $1 WHERE id = $2", ordersTable)
-	_, err := r.db.Exec(query, status, orderId)
+func (r *OrdersPostgres) updateOrderStatus(tx *sql.Tx, orderId int, fromStatus string, status string) error {
+	query := fmt.Sprintf("UPDATE %s SET status = $1 WHERE id = $2 AND status = $3", ordersTable)
+	result, err := tx.Exec(query, status, orderId, fromStatus)
 	if err != nil {
 		return err
 	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrOrderStatusChanged
+	}
 	return nil
 }
 
+func (r *OrdersPostgres) addStatusHistory(tx *sql.Tx, orderId int, fromStatus string, status string, changedBy int) error {
+	query := fmt.Sprintf("INSERT INTO %s (order_id, from_status, to_status, changed_by) VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, 0))", orderStatusHistoryTable)
+	if _, err := tx.Exec(query, orderId, fromStatus, status, changedBy); err != nil {
+		return err
+	}
+	return enqueueOrderNotification(tx, orderId, fromStatus, status)
+}
+
+func (r *OrdersPostgres) GetOrderStatusHistory(orderId int) ([]model.OrderStatusChange, error) {
+	var history []model.OrderStatusChange
+	query := fmt.Sprintf("SELECT h.*, u.name as changed_by_name FROM %s h LEFT JOIN %s u ON h.changed_by = u.id WHERE h.order_id = $1 ORDER BY h.created_at, h.id", orderStatusHistoryTable, usersTable)
+	if err := r.db.Select(&history, query, orderId); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// CancelOrder cancels the order and, when refund has an amount, records the
+// refund of its payment in the same transaction.
+func (r *OrdersPostgres) CancelOrder(orderId int, fromStatus string, changedBy int, refund model.Refund) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	var stockCommitted bool
+	query := fmt.Sprintf("SELECT stock_committed FROM %s WHERE id = $1 FOR UPDATE", ordersTable)
+	if err := tx.QueryRow(query, orderId).Scan(&stockCommitted); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := r.updateOrderStatus(tx, orderId, fromStatus, cancelledStatus); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if stockCommitted {
+		err = r.restoreOrderStock(tx, orderId)
+	} else {
+		err = releaseOrderReservations(tx, orderId)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := releasePromoCodeUsage(tx, orderId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if refund.Amount > 0 {
+		if err := createRefund(tx, orderId, refund); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := r.addStatusHistory(tx, orderId, fromStatus, cancelledStatus, changedBy); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *OrdersPostgres) restoreOrderStock(tx *sql.Tx, orderId int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s s SET amount = s.amount + (op.amount - op.returned_amount)
+		FROM %s op
+		WHERE op.order_id = $1 AND s.product_id = op.product_id AND s.name = op.size AND op.amount > op.returned_amount`,
+		sizesTable, orderedProductsTable)
+	_, err := tx.Exec(query, orderId)
+	return err
+}
+
+func (r *OrdersPostgres) ReturnOrderedProducts(orderId int, fromStatus string, returnedProducts []model.ReturnedProduct, changedBy int, refund model.Refund) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	var currentStatus string
+	query := fmt.Sprintf("SELECT status FROM %s WHERE id = $1 FOR UPDATE", ordersTable)
+	if err := tx.QueryRow(query, orderId).Scan(&currentStatus); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if currentStatus != fromStatus {
+		tx.Rollback()
+		return false, ErrOrderStatusChanged
+	}
+	for _, returnedProduct := range returnedProducts {
+		var productId int
+		var size string
+		query = fmt.Sprintf("UPDATE %s SET returned_amount = returned_amount + $1 WHERE id = $2 AND order_id = $3 AND returned_amount + $1 <= amount RETURNING product_id, size", orderedProductsTable)
+		if err := tx.QueryRow(query, returnedProduct.Amount, returnedProduct.OrderedProductID, orderId).Scan(&productId, &size); err != nil {
+			tx.Rollback()
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, ErrInvalidReturnAmount
+			}
+			return false, err
+		}
+		query = fmt.Sprintf("UPDATE %s SET amount = amount + $1 WHERE product_id = $2 AND name = $3", sizesTable)
+		if _, err := tx.Exec(query, returnedProduct.Amount, productId, size); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+	if refund.Amount > 0 {
+		if err := createRefund(tx, orderId, refund); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+	var remaining int
+	query = fmt.Sprintf("SELECT COALESCE(SUM(amount - returned_amount), 0) FROM %s WHERE order_id = $1", orderedProductsTable)
+	if err := tx.QueryRow(query, orderId).Scan(&remaining); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	fullyReturned := remaining == 0
+	if fullyReturned {
+		if err := r.updateOrderStatus(tx, orderId, fromStatus, returnedStatus); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		if err := r.addStatusHistory(tx, orderId, fromStatus, returnedStatus, changedBy); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return fullyReturned, nil
+}
+
 func (r *OrdersPostgres) SetDeliveryId(orderId int, deliveryId string) error {
 	query := fmt.Sprintf("UPDATE %s SET delivery_id = $1 WHERE id = $2", ordersTable)
 	_, err := r.db.Exec(query, deliveryId, orderId)
@@ -190,3 +461,50 @@ func (r *OrdersPostgres) SetPickupId(orderId int, pickupId string) error {
 	}
 	return nil
 }
+
+// SetPaymentId replaces the order's payment only if it is still oldPaymentId,
+// so concurrent requests cannot both attach a payment.
+func (r *OrdersPostgres) SetPaymentId(orderId int, oldPaymentId string, paymentId string) error {
+	query := fmt.Sprintf("UPDATE %s SET payment_id = $1 WHERE id = $2 AND COALESCE(payment_id, '') = $3", ordersTable)
+	result, err := r.db.Exec(query, paymentId, orderId, oldPaymentId)
+	if err != nil {
+		return err
+	}
+	return checkRowsAffected(result)
+}
+
+func (r *OrdersPostgres) GetOrderByPaymentId(paymentId string) (model.Order, error) {
+	var orderId int
+	query := fmt.Sprintf("SELECT id FROM %s WHERE payment_id = $1", ordersTable)
+	if err := r.db.Get(&orderId, query, paymentId); err != nil {
+		return model.Order{}, err
+	}
+	return r.GetOrder(orderId)
+}
+
+func (r *OrdersPostgres) GetUnpaidOrders(createdBefore time.Time) ([]model.Order, error) {
+	var orders []model.Order
+	query := fmt.Sprintf("SELECT * FROM %s WHERE status = $1 AND created_at < $2 ORDER BY created_at", ordersTable)
+	if err := r.db.Select(&orders, query, pendingStatus, createdBefore); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *OrdersPostgres) GetOrderWeight(orderId int) (int, error) {
+	var weight int
+	query := fmt.Sprintf("SELECT COALESCE(SUM(op.amount * p.weight), 0) FROM %s op JOIN %s p ON op.product_id = p.id WHERE op.order_id = $1", orderedProductsTable, productsTable)
+	if err := r.db.Get(&weight, query, orderId); err != nil {
+		return 0, err
+	}
+	return weight, nil
+}
+
+func (r *OrdersPostgres) GetShippedOrders() ([]model.Order, error) {
+	var orders []model.Order
+	query := fmt.Sprintf("SELECT * FROM %s WHERE type = $1 AND status IN ($2, $3) AND delivery_id IS NOT NULL AND delivery_id <> ''", ordersTable)
+	if err := r.db.Select(&orders, query, deliveryType, createdStatus, sentToCustomerStatus); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
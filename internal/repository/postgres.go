@@ -7,15 +7,16 @@ import (
 )
 
 const (
-	usersTable           = "users"
-	productsTable        = "products"
-	ordersTable          = "orders"
-	productsMediaTable   = "media"
-	orderedProductsTable = "ordered_products"
-	likedProductsTable   = "liked_products"
-	productsInCartTable  = "products_in_cart"
-	sizesTable           = "sizes"
-	collectionsTable     = "collections"
+	usersTable              = "users"
+	productsTable           = "products"
+	ordersTable             = "orders"
+	productsMediaTable      = "media"
+	orderedProductsTable    = "ordered_products"
+	likedProductsTable      = "liked_products"
+	productsInCartTable     = "products_in_cart"
+	sizesTable              = "sizes"
+	collectionsTable        = "collections"
+	orderStatusHistoryTable = "order_status_history"
 )
 
 type PostgresConfig struct {
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -11,12 +12,28 @@ import (
 )
 
 const (
-	adminRole    = "admin"
-	buyerRole    = "buyer"
-	customerRole = "customer"
-	pageLimit    = 21
+	adminRole          = "admin"
+	buyerRole          = "buyer"
+	customerRole       = "customer"
+	pageLimit          = 21
+	productPricesTable = "product_prices"
 )
 
+// Effective price is the latest scheduled price active right now, falling back
+// to products.price. Original price is the compare-at price of that schedule
+// or the regular price, whichever is used to show a crossed-out price.
+const (
+	effectivePriceColumn = "COALESCE(pp.price, p.price, 0)"
+	originalPriceColumn  = "GREATEST(COALESCE(pp.compare_at_price, p.price, 0), COALESCE(pp.price, p.price, 0))"
+	productColumns       = "p.id, p.name, p.description, " + effectivePriceColumn + " as price, " + originalPriceColumn + " as original_price, " + effectivePriceColumn + " < " + originalPriceColumn + " as on_sale, p.collection_id, p.category, p.color, p.weight, p.main_photo_url"
+)
+
+var effectivePriceJoin = fmt.Sprintf(`LEFT JOIN LATERAL (
+		SELECT pr.price, pr.compare_at_price FROM %s pr
+		WHERE pr.product_id = p.id AND pr.effective_from <= now() AND (pr.effective_to IS NULL OR pr.effective_to > now())
+		ORDER BY pr.effective_from DESC, pr.id DESC LIMIT 1
+	) pp ON TRUE`, productPricesTable)
+
 type ProductsPostgres struct {
 	db *sqlx.DB
 }
@@ -33,8 +50,8 @@ func (r *ProductsPostgres) CreateProduct(product model.Product) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	query := fmt.Sprintf("INSERT INTO %s (name, description, price, collection_id, category, color) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", productsTable)
-	row := tx.QueryRow(query, product.Name, product.Description, product.Price, product.CollectionID, product.Category, product.Color)
+	query := fmt.Sprintf("INSERT INTO %s (name, description, price, collection_id, category, color, weight) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id", productsTable)
+	row := tx.QueryRow(query, product.Name, product.Description, product.Price, product.CollectionID, product.Category, product.Color, product.Weight)
 
 	if err := row.Scan(&productId); err != nil {
 		tx.Rollback()
@@ -52,7 +69,7 @@ func (r *ProductsPostgres) CreateProduct(product model.Product) (int, error) {
 }
 
 func (r *ProductsPostgres) GetProduct(productId int, userId int) (model.Product, error) {
-	query := fmt.Sprintf("SELECT p.*, c.name as collection_name, EXISTS(SELECT 1 FROM %s lp WHERE lp.product_id = p.id AND lp.user_id = $2) as is_liked FROM %s p JOIN %s c ON p.collection_id = c.id WHERE p.id = $1", likedProductsTable, productsTable, collectionsTable)
+	query := fmt.Sprintf("SELECT %s, c.name as collection_name, EXISTS(SELECT 1 FROM %s lp WHERE lp.product_id = p.id AND lp.user_id = $2) as is_liked FROM %s p JOIN %s c ON p.collection_id = c.id %s WHERE p.id = $1", productColumns, likedProductsTable, productsTable, collectionsTable, effectivePriceJoin)
 	var product model.Product
 	if err := r.db.Get(&product, query, productId, userId); err != nil {
 		return model.Product{}, err
@@ -60,15 +77,16 @@ func (r *ProductsPostgres) GetProduct(productId int, userId int) (model.Product,
 	return product, nil
 }
 
-func (r *ProductsPostgres) GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, page int, userId int) ([]model.Product, error) {
+func (r *ProductsPostgres) GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, onSale bool, page int, userId int) ([]model.Product, error) {
 	query := fmt.Sprintf(`
 		SELECT DISTINCT 
-			p.*,
+			%s,
 			c.name as collection_name,
 			EXISTS(SELECT 1 FROM %s lp WHERE lp.product_id = p.id AND lp.user_id = $1) as is_liked 
 		FROM %s p
-		LEFT JOIN %s c ON p.collection_id = c.id`,
-		likedProductsTable, productsTable, collectionsTable)
+		LEFT JOIN %s c ON p.collection_id = c.id
+		%s`,
+		productColumns, likedProductsTable, productsTable, collectionsTable, effectivePriceJoin)
 
 	args := []interface{}{userId}
 	where := ""
@@ -100,17 +118,21 @@ func (r *ProductsPostgres) GetProducts(collectionId int, category string, colors
 	}
 
 	if minPrice > 0 {
-		where += fmt.Sprintf("p.price >= $%d AND ", argIdx)
+		where += fmt.Sprintf("%s >= $%d AND ", effectivePriceColumn, argIdx)
 		args = append(args, minPrice)
 		argIdx++
 	}
 
 	if maxPrice > 0 {
-		where += fmt.Sprintf("p.price <= $%d AND ", argIdx)
+		where += fmt.Sprintf("%s <= $%d AND ", effectivePriceColumn, argIdx)
 		args = append(args, maxPrice)
 		argIdx++
 	}
 
+	if onSale {
+		where += fmt.Sprintf("%s < %s AND ", effectivePriceColumn, originalPriceColumn)
+	}
+
 	if len(sizes) > 0 {
 		query += fmt.Sprintf(" LEFT JOIN %s s ON s.product_id = p.id", sizesTable)
 		where += "("
@@ -118,7 +140,7 @@ func (r *ProductsPostgres) GetProducts(collectionId int, category string, colors
 			if i > 0 {
 				where += " OR "
 			}
-			where += fmt.Sprintf("(s.name = $%d AND s.amount > 0)", argIdx)
+			where += fmt.Sprintf("(s.name = $%d AND s.amount > COALESCE((SELECT SUM(sr.amount) FROM %s sr WHERE sr.product_id = s.product_id AND sr.size = s.name AND sr.expires_at > now()), 0))", argIdx, stockReservationsTable)
 			args = append(args, size)
 			argIdx++
 		}
@@ -184,28 +206,108 @@ func (r *ProductsPostgres) AddProductSizes(tx *sql.Tx, productId int, sizes []mo
 	return err
 }
 
-func (r *ProductsPostgres) AddProductToCart(userId int, productId int, size string, amount int) error {
-	query := fmt.Sprintf("INSERT INTO %s (user_id, product_id, size, amount) VALUES ($1, $2, $3, $4)", productsInCartTable)
-	_, err := r.db.Exec(query, userId, productId, size, amount)
-	return err
+// cartOwnerColumn returns the column and value that select the owner's cart
+// lines.
+func cartOwnerColumn(owner model.CartOwner) (string, interface{}) {
+	if owner.GuestCartID != "" {
+		return "guest_cart_id", owner.GuestCartID
+	}
+	return "user_id", owner.UserID
 }
 
-func (r *ProductsPostgres) RemoveProductFromCart(userId int, productId int) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND product_id = $2", productsInCartTable)
-	_, err := r.db.Exec(query, userId, productId)
+// AddProductToCart adds amount to the owner's line of the product and size,
+// failing with ErrNotEnoughStock when the line would exceed the size stock.
+func (r *ProductsPostgres) AddProductToCart(owner model.CartOwner, productId int, size string, amount int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	var stock int
+	query := fmt.Sprintf("SELECT amount FROM %s WHERE product_id = $1 AND name = $2 FOR SHARE", sizesTable)
+	if err := tx.QueryRow(query, productId, size).Scan(&stock); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotEnoughStock
+		}
+		return err
+	}
+	if owner.GuestCartID != "" {
+		query := fmt.Sprintf("INSERT INTO %s (id) VALUES ($1) ON CONFLICT (id) DO UPDATE SET updated_at = now()", guestCartsTable)
+		if _, err := tx.Exec(query, owner.GuestCartID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	column, value := cartOwnerColumn(owner)
+	query = fmt.Sprintf(`INSERT INTO %[1]s (%[2]s, product_id, size, amount) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (%[2]s, product_id, size) WHERE %[2]s IS NOT NULL DO UPDATE SET amount = %[1]s.amount + EXCLUDED.amount, existence = TRUE
+		RETURNING amount`, productsInCartTable, column)
+	var lineAmount int
+	if err := tx.QueryRow(query, value, productId, size, amount).Scan(&lineAmount); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if lineAmount > stock {
+		tx.Rollback()
+		return ErrNotEnoughStock
+	}
+	return tx.Commit()
+}
+
+// RemoveProductFromCart removes the owner's lines of the product, only the one
+// of the given size when size is not empty.
+func (r *ProductsPostgres) RemoveProductFromCart(owner model.CartOwner, productId int, size string) error {
+	column, value := cartOwnerColumn(owner)
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1 AND product_id = $2", productsInCartTable, column)
+	args := []interface{}{value, productId}
+	if size != "" {
+		query += " AND size = $3"
+		args = append(args, size)
+	}
+	_, err := r.db.Exec(query, args...)
 	return err
 }
 
-func (r *ProductsPostgres) GetProductsInCart(userId int) ([]model.ProductInCart, error) {
-	query := fmt.Sprintf("SELECT c.*, p.name as product_name, p.main_photo_url, p.price FROM %s c JOIN %s p ON c.product_id = p.id WHERE c.user_id = $1", productsInCartTable, productsTable)
-	rows, err := r.db.Query(query, userId)
+func (r *ProductsPostgres) UpdateCartLine(owner model.CartOwner, lineId int, amount int) error {
+	tx, err := r.db.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	column, value := cartOwnerColumn(owner)
+	var stock sql.NullInt64
+	query := fmt.Sprintf(`SELECT s.amount FROM %s c LEFT JOIN %s s ON s.product_id = c.product_id AND s.name = c.size
+		WHERE c.id = $1 AND c.%s = $2 FOR UPDATE OF c`, productsInCartTable, sizesTable, column)
+	if err := tx.QueryRow(query, lineId, value).Scan(&stock); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !stock.Valid || int64(amount) > stock.Int64 {
+		tx.Rollback()
+		return ErrNotEnoughStock
+	}
+	query = fmt.Sprintf("UPDATE %s SET amount = $1, existence = TRUE WHERE id = $2", productsInCartTable)
+	if _, err := tx.Exec(query, amount, lineId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ProductsPostgres) RemoveCartLine(owner model.CartOwner, lineId int) error {
+	column, value := cartOwnerColumn(owner)
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND %s = $2", productsInCartTable, column)
+	result, err := r.db.Exec(query, lineId, value)
+	if err != nil {
+		return err
+	}
+	return checkRowsAffected(result)
+}
 
+func (r *ProductsPostgres) GetProductsInCart(owner model.CartOwner) ([]model.ProductInCart, error) {
+	column, value := cartOwnerColumn(owner)
+	query := fmt.Sprintf("SELECT c.*, p.name as product_name, p.main_photo_url, %s as price, %s as original_price, p.weight, p.collection_id, p.category FROM %s c JOIN %s p ON c.product_id = p.id %s WHERE c.%s = $1", effectivePriceColumn, originalPriceColumn, productsInCartTable, productsTable, effectivePriceJoin, column)
 	var productsInCart []model.ProductInCart
-	if err := r.db.Select(&productsInCart, query, userId); err != nil {
+	if err := r.db.Select(&productsInCart, query, value); err != nil {
 		return nil, err
 	}
 	return productsInCart, nil
@@ -224,7 +326,7 @@ func (r *ProductsPostgres) RemoveProductFromLiked(userId int, productId int) err
 }
 
 func (r *ProductsPostgres) GetLikedProducts(userId int) ([]model.Product, error) {
-	query := fmt.Sprintf("SELECT p.*, c.name as collection_name, EXISTS(SELECT 1 FROM %s lp WHERE lp.product_id = p.id AND lp.user_id = $1) as is_liked FROM %s lp JOIN %s p ON lp.product_id = p.id JOIN %s c ON p.collection_id = c.id WHERE lp.user_id = $1", likedProductsTable, likedProductsTable, productsTable, collectionsTable)
+	query := fmt.Sprintf("SELECT %s, c.name as collection_name, EXISTS(SELECT 1 FROM %s lp WHERE lp.product_id = p.id AND lp.user_id = $1) as is_liked FROM %s lp JOIN %s p ON lp.product_id = p.id JOIN %s c ON p.collection_id = c.id %s WHERE lp.user_id = $1", productColumns, likedProductsTable, likedProductsTable, productsTable, collectionsTable, effectivePriceJoin)
 	var products []model.Product
 	if err := r.db.Select(&products, query, userId); err != nil {
 		return nil, err
@@ -347,15 +449,52 @@ func (r *ProductsPostgres) SearchProducts(userId int, userQuery string) ([]model
 
 	query := fmt.Sprintf(`
 	SELECT DISTINCT 
-		p.*,
+		%s,
 		c.name as collection_name,
 		EXISTS(SELECT 1 FROM %s lp WHERE lp.product_id = p.id AND lp.user_id = $1) as is_liked 
 	FROM %s p
-	LEFT JOIN %s c ON p.collection_id = c.id WHERE p.name ILIKE $2`,
-		likedProductsTable, productsTable, collectionsTable)
+	LEFT JOIN %s c ON p.collection_id = c.id
+	%s
+	WHERE p.name ILIKE $2`,
+		productColumns, likedProductsTable, productsTable, collectionsTable, effectivePriceJoin)
 	var products []model.Product
 	if err := r.db.Select(&products, query, userId, "%"+userQuery+"%"); err != nil {
 		return nil, err
 	}
 	return products, nil
 }
+
+func (r *ProductsPostgres) CreateProductPrice(price model.ProductPrice) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (product_id, price, compare_at_price, effective_from, effective_to) VALUES ($1, $2, $3, $4, $5) RETURNING id", productPricesTable)
+	row := r.db.QueryRow(query, price.ProductID, price.Price, price.CompareAtPrice, price.EffectiveFrom, price.EffectiveTo)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *ProductsPostgres) GetProductPrices(productId int) ([]model.ProductPrice, error) {
+	prices := make([]model.ProductPrice, 0)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE product_id = $1 ORDER BY effective_from DESC, id DESC", productPricesTable)
+	if err := r.db.Select(&prices, query, productId); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (r *ProductsPostgres) DeleteProductPrice(productId int, priceId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND product_id = $2", productPricesTable)
+	result, err := r.db.Exec(query, priceId, productId)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lib/pq"
+)
+
+const (
+	promoCodesTable      = "promo_codes"
+	promoCodeUsagesTable = "promo_code_usages"
+)
+
+var (
+	ErrPromoCodeExists       = errors.New("promo code with this code already exists")
+	ErrPromoCodeInUse        = errors.New("promo code was already used, deactivate it instead")
+	ErrPromoCodeLimitReached = errors.New("promo code usage limit reached")
+)
+
+type PromoCodesPostgres struct {
+	db *sqlx.DB
+}
+
+func NewPromoCodesPostgres(db *sqlx.DB) *PromoCodesPostgres {
+	return &PromoCodesPostgres{db: db}
+}
+
+func (r *PromoCodesPostgres) CreatePromoCode(promoCode model.PromoCode) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (code, discount_type, value, min_order_sum, valid_from, valid_to, usage_limit, usage_limit_per_user, collection_id, category, is_active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id", promoCodesTable)
+	row := r.db.QueryRow(query, promoCode.Code, promoCode.DiscountType, promoCode.Value, promoCode.MinOrderSum, promoCode.ValidFrom, promoCode.ValidTo, promoCode.UsageLimit, promoCode.UsageLimitPerUser, promoCode.CollectionID, promoCode.Category, promoCode.IsActive)
+	if err := row.Scan(&id); err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return 0, ErrPromoCodeExists
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *PromoCodesPostgres) GetPromoCodes() ([]model.PromoCode, error) {
+	promoCodes := make([]model.PromoCode, 0)
+	query := fmt.Sprintf("SELECT * FROM %s ORDER BY id DESC", promoCodesTable)
+	if err := r.db.Select(&promoCodes, query); err != nil {
+		return nil, err
+	}
+	return promoCodes, nil
+}
+
+func (r *PromoCodesPostgres) GetPromoCode(promoCodeId int) (model.PromoCode, error) {
+	var promoCode model.PromoCode
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1", promoCodesTable)
+	if err := r.db.Get(&promoCode, query, promoCodeId); err != nil {
+		return model.PromoCode{}, err
+	}
+	return promoCode, nil
+}
+
+func (r *PromoCodesPostgres) GetPromoCodeByCode(code string) (model.PromoCode, error) {
+	var promoCode model.PromoCode
+	query := fmt.Sprintf("SELECT * FROM %s WHERE code = $1", promoCodesTable)
+	if err := r.db.Get(&promoCode, query, code); err != nil {
+		return model.PromoCode{}, err
+	}
+	return promoCode, nil
+}
+
+func (r *PromoCodesPostgres) UpdatePromoCode(promoCodeId int, promoCode model.PromoCode) error {
+	query := fmt.Sprintf("UPDATE %s SET code = $1, discount_type = $2, value = $3, min_order_sum = $4, valid_from = $5, valid_to = $6, usage_limit = $7, usage_limit_per_user = $8, collection_id = $9, category = $10, is_active = $11 WHERE id = $12", promoCodesTable)
+	_, err := r.db.Exec(query, promoCode.Code, promoCode.DiscountType, promoCode.Value, promoCode.MinOrderSum, promoCode.ValidFrom, promoCode.ValidTo, promoCode.UsageLimit, promoCode.UsageLimitPerUser, promoCode.CollectionID, promoCode.Category, promoCode.IsActive, promoCodeId)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+		return ErrPromoCodeExists
+	}
+	return err
+}
+
+func (r *PromoCodesPostgres) DeletePromoCode(promoCodeId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", promoCodesTable)
+	_, err := r.db.Exec(query, promoCodeId)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+		return ErrPromoCodeInUse
+	}
+	return err
+}
+
+func (r *PromoCodesPostgres) CountPromoCodeUsages(promoCodeId int, userId int) (int, int, error) {
+	var total, byUser int
+	query := fmt.Sprintf("SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2) FROM %s WHERE promo_code_id = $1", promoCodeUsagesTable)
+	if err := r.db.QueryRow(query, promoCodeId, userId).Scan(&total, &byUser); err != nil {
+		return 0, 0, err
+	}
+	return total, byUser, nil
+}
+
+func usePromoCode(tx *sql.Tx, promoCodeId int, userId int, orderId int) error {
+	var usageLimit, usageLimitPerUser sql.NullInt64
+	query := fmt.Sprintf("SELECT usage_limit, usage_limit_per_user FROM %s WHERE id = $1 FOR UPDATE", promoCodesTable)
+	if err := tx.QueryRow(query, promoCodeId).Scan(&usageLimit, &usageLimitPerUser); err != nil {
+		return err
+	}
+	var total, byUser int64
+	query = fmt.Sprintf("SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2) FROM %s WHERE promo_code_id = $1", promoCodeUsagesTable)
+	if err := tx.QueryRow(query, promoCodeId, userId).Scan(&total, &byUser); err != nil {
+		return err
+	}
+	if (usageLimit.Valid && total >= usageLimit.Int64) || (usageLimitPerUser.Valid && byUser >= usageLimitPerUser.Int64) {
+		return ErrPromoCodeLimitReached
+	}
+	query = fmt.Sprintf("INSERT INTO %s (promo_code_id, user_id, order_id) VALUES ($1, $2, $3)", promoCodeUsagesTable)
+	_, err := tx.Exec(query, promoCodeId, userId, orderId)
+	return err
+}
+
+func releasePromoCodeUsage(tx *sql.Tx, orderId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE order_id = $1", promoCodeUsagesTable)
+	_, err := tx.Exec(query, orderId)
+	return err
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	rateLimitsTable    = "rate_limits"
+	loginFailuresTable = "login_failures"
+)
+
+type RateLimitsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRateLimitsPostgres(db *sqlx.DB) *RateLimitsPostgres {
+	return &RateLimitsPostgres{db: db}
+}
+
+// TakeRateLimit spends one request from the bucket of the key. The bucket is
+// stored as the moment it will be full again: every request moves it forward
+// by interval, and a request is allowed while that moment is no more than
+// window ahead. When the request is denied it returns how long to wait.
+func (r *RateLimitsPostgres) TakeRateLimit(key string, interval time.Duration, window time.Duration) (bool, time.Duration, error) {
+	var refilledAt time.Time
+	query := fmt.Sprintf(`INSERT INTO %[1]s (key, refilled_at) VALUES ($1, now() + $2::bigint * interval '1 microsecond')
+		ON CONFLICT (key) DO UPDATE SET refilled_at = GREATEST(%[1]s.refilled_at, now()) + $2::bigint * interval '1 microsecond'
+		WHERE GREATEST(%[1]s.refilled_at, now()) + $2::bigint * interval '1 microsecond' <= now() + $3::bigint * interval '1 microsecond'
+		RETURNING refilled_at`, rateLimitsTable)
+	err := r.db.QueryRow(query, key, interval.Microseconds(), window.Microseconds()).Scan(&refilledAt)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, err
+	}
+	var retryAfter float64
+	query = fmt.Sprintf(`SELECT EXTRACT(EPOCH FROM GREATEST(refilled_at, now()) + $2::bigint * interval '1 microsecond' - now() - $3::bigint * interval '1 microsecond')
+		FROM %s WHERE key = $1`, rateLimitsTable)
+	if err := r.db.Get(&retryAfter, query, key, interval.Microseconds(), window.Microseconds()); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, err
+	}
+	return false, time.Duration(retryAfter * float64(time.Second)), nil
+}
+
+func (r *RateLimitsPostgres) DeleteExpiredRateLimits() (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE refilled_at < now()", rateLimitsTable)
+	result, err := r.db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *RateLimitsPostgres) GetLoginLockedUntil(account string) (*time.Time, error) {
+	var lockedUntil *time.Time
+	query := fmt.Sprintf("SELECT locked_until FROM %s WHERE account = $1", loginFailuresTable)
+	if err := r.db.Get(&lockedUntil, query, account); err != nil {
+		return nil, err
+	}
+	return lockedUntil, nil
+}
+
+// RegisterLoginFailure counts a failed sign in and returns the number of
+// failures since the last successful one.
+func (r *RateLimitsPostgres) RegisterLoginFailure(account string) (int, error) {
+	var failures int
+	query := fmt.Sprintf(`INSERT INTO %[1]s (account, failures) VALUES ($1, 1)
+		ON CONFLICT (account) DO UPDATE SET failures = %[1]s.failures + 1, updated_at = now()
+		RETURNING failures`, loginFailuresTable)
+	if err := r.db.QueryRow(query, account).Scan(&failures); err != nil {
+		return 0, err
+	}
+	return failures, nil
+}
+
+func (r *RateLimitsPostgres) LockLogin(account string, lockedUntil time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET locked_until = $1 WHERE account = $2", loginFailuresTable)
+	_, err := r.db.Exec(query, lockedUntil, account)
+	return err
+}
+
+func (r *RateLimitsPostgres) ResetLoginFailures(account string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE account = $1", loginFailuresTable)
+	_, err := r.db.Exec(query, account)
+	return err
+}
+
+func (r *RateLimitsPostgres) DeleteStaleLoginFailures(updatedBefore time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE updated_at < $1 AND (locked_until IS NULL OR locked_until < now())", loginFailuresTable)
+	result, err := r.db.Exec(query, updatedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const refreshTokensTable = "refresh_tokens"
+
+type RefreshTokensPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRefreshTokensPostgres(db *sqlx.DB) *RefreshTokensPostgres {
+	return &RefreshTokensPostgres{db: db}
+}
+
+func (r *RefreshTokensPostgres) CreateRefreshToken(token model.RefreshToken) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (user_id, token_hash, family_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", refreshTokensTable)
+	row := r.db.QueryRow(query, token.UserID, token.TokenHash, token.FamilyID, token.UserAgent, token.IP, token.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *RefreshTokensPostgres) GetRefreshTokenByHash(tokenHash string) (model.RefreshToken, error) {
+	var token model.RefreshToken
+	query := fmt.Sprintf("SELECT * FROM %s WHERE token_hash = $1", refreshTokensTable)
+	if err := r.db.Get(&token, query, tokenHash); err != nil {
+		return model.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+func (r *RefreshTokensPostgres) MarkRefreshTokenUsed(tokenId int) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET used_at = now() WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL", refreshTokensTable)
+	result, err := r.db.Exec(query, tokenId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *RefreshTokensPostgres) RevokeRefreshTokenFamily(familyId string) error {
+	query := fmt.Sprintf("UPDATE %s SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL", refreshTokensTable)
+	_, err := r.db.Exec(query, familyId)
+	return err
+}
+
+func (r *RefreshTokensPostgres) RevokeUserRefreshTokens(userId int) error {
+	query := fmt.Sprintf("UPDATE %s SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL", refreshTokensTable)
+	_, err := r.db.Exec(query, userId)
+	return err
+}
+
+func (r *RefreshTokensPostgres) RevokeSession(userId int, sessionId int) error {
+	query := fmt.Sprintf("UPDATE %s SET revoked_at = now() WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM %s WHERE id = $1 AND user_id = $2)", refreshTokensTable, refreshTokensTable)
+	result, err := r.db.Exec(query, sessionId, userId)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *RefreshTokensPostgres) GetActiveSessions(userId int) ([]model.Session, error) {
+	sessions := make([]model.Session, 0)
+	query := fmt.Sprintf(`
+		SELECT t.id, t.user_agent, t.ip, t.expires_at, t.created_at as last_refreshed_at,
+			(SELECT MIN(f.created_at) FROM %s f WHERE f.family_id = t.family_id) as started_at
+		FROM %s t
+		WHERE t.user_id = $1 AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > now()
+		ORDER BY t.created_at DESC`,
+		refreshTokensTable, refreshTokensTable)
+	if err := r.db.Select(&sessions, query, userId); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *RefreshTokensPostgres) DeleteExpiredRefreshTokens(expiredBefore time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires_at < $1", refreshTokensTable)
+	result, err := r.db.Exec(query, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const refundsTable = "refunds"
+
+type RefundsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRefundsPostgres(db *sqlx.DB) *RefundsPostgres {
+	return &RefundsPostgres{db: db}
+}
+
+// createRefund records a refund of the order's payment in the transaction that
+// changes the order, so the money is returned only for a committed change.
+func createRefund(tx *sql.Tx, orderId int, refund model.Refund) error {
+	query := fmt.Sprintf(`INSERT INTO %s (order_id, payment_id, amount, reason)
+		SELECT id, payment_id, $2, $3 FROM %s WHERE id = $1 AND COALESCE(payment_id, '') <> ''
+		ON CONFLICT DO NOTHING`, refundsTable, ordersTable)
+	_, err := tx.Exec(query, orderId, refund.Amount, refund.Reason)
+	return err
+}
+
+// CreateRefund records a refund of the payment. A payment gets at most one
+// unfulfilled refund, so repeated webhooks do not refund it again.
+func (r *RefundsPostgres) CreateRefund(refund model.Refund) error {
+	query := fmt.Sprintf("INSERT INTO %s (order_id, payment_id, amount, reason) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING", refundsTable)
+	_, err := r.db.Exec(query, refund.OrderID, refund.PaymentID, refund.Amount, refund.Reason)
+	return err
+}
+
+// GetPendingRefunds returns refunds not yet sent to the provider, of all
+// orders when orderId is zero.
+func (r *RefundsPostgres) GetPendingRefunds(orderId int) ([]model.Refund, error) {
+	var refunds []model.Refund
+	query := fmt.Sprintf("SELECT * FROM %s WHERE refunded_at IS NULL AND ($1 = 0 OR order_id = $1) ORDER BY id", refundsTable)
+	if err := r.db.Select(&refunds, query, orderId); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+func (r *RefundsPostgres) CompleteRefund(refundId int) error {
+	query := fmt.Sprintf("UPDATE %s SET refunded_at = now() WHERE id = $1", refundsTable)
+	_, err := r.db.Exec(query, refundId)
+	return err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lavatee/dresscode_backend/internal/model"
@@ -10,42 +11,146 @@ import (
 type Auth interface {
 	CreateAdmin(name string, email string, password string) error
 	CreateUser(user model.User) (int, error)
-	SignIn(email, password_hash string) (int, error)
+	GetUserByEmail(email string) (model.User, error)
+	UpdatePasswordHash(userId int, passwordHash string) error
+	MarkEmailVerified(userId int) error
 	NewAdmin(thisAdminId int, newAdminId int) error
 	NewBuyer(thisAdminId int, newBuyerId int) error
 	IsAdmin(userId int) bool
+	IsBuyer(userId int) bool
 	GetUserRole(userId int) (string, error)
 	GetUser(userId int) (model.User, error)
 	RemoveBuyer(thisAdminId int, buyerId int) error
+	GetTokenVersion(userId int) (int, error)
+	BumpTokenVersion(userId int) error
+	UpdateUser(userId int, update model.UserUpdate) error
+	SetPendingEmail(userId int, email string) error
+	ConfirmPendingEmail(userId int) error
+	DeleteUser(userId int) error
+}
+
+type RefreshTokens interface {
+	CreateRefreshToken(token model.RefreshToken) (int, error)
+	GetRefreshTokenByHash(tokenHash string) (model.RefreshToken, error)
+	MarkRefreshTokenUsed(tokenId int) (bool, error)
+	RevokeRefreshTokenFamily(familyId string) error
+	RevokeUserRefreshTokens(userId int) error
+	RevokeSession(userId int, sessionId int) error
+	GetActiveSessions(userId int) ([]model.Session, error)
+	DeleteExpiredRefreshTokens(expiredBefore time.Time) (int64, error)
+}
+
+type UserTokens interface {
+	CreateUserToken(token model.UserToken) (int, error)
+	GetUserTokenByHash(tokenHash string) (model.UserToken, error)
+	UseUserToken(tokenId int) (bool, error)
+	InvalidateUserTokens(userId int, purpose string) error
+	DeleteExpiredUserTokens(expiredBefore time.Time) (int64, error)
+}
+
+type TwoFactor interface {
+	GetTwoFactor(userId int) (model.TwoFactor, error)
+	SetTOTPSecret(userId int, secret string) error
+	EnableTwoFactor(userId int, step int64, recoveryCodeHashes []string) error
+	DisableTwoFactor(userId int) error
+	UseTOTPStep(userId int, step int64) (bool, error)
+	ReplaceRecoveryCodes(userId int, recoveryCodeHashes []string) error
+	UseRecoveryCode(userId int, codeHash string) (bool, error)
+}
+
+type GuestCarts interface {
+	MergeGuestCart(guestCartId string, userId int) error
+	DeleteExpiredGuestCarts(updatedBefore time.Time) (int64, error)
+}
+
+type Identities interface {
+	GetIdentity(provider string, subject string) (model.Identity, error)
+	GetUserIdentities(userId int) ([]model.Identity, error)
+	LinkIdentity(identity model.Identity) error
+	CreateUserWithIdentity(user model.User, identity model.Identity, emailVerified bool) (int, error)
+}
+
+type RateLimits interface {
+	TakeRateLimit(key string, interval time.Duration, window time.Duration) (bool, time.Duration, error)
+	DeleteExpiredRateLimits() (int64, error)
+	GetLoginLockedUntil(account string) (*time.Time, error)
+	RegisterLoginFailure(account string) (int, error)
+	LockLogin(account string, lockedUntil time.Time) error
+	ResetLoginFailures(account string) error
+	DeleteStaleLoginFailures(updatedBefore time.Time) (int64, error)
 }
 
 type Products interface {
 	CreateProduct(product model.Product) (int, error)
 	GetProduct(productId int, userId int) (model.Product, error)
-	GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, page int, userId int) ([]model.Product, error)
+	GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, onSale bool, page int, userId int) ([]model.Product, error)
 	DeleteProduct(productId int) error
 	AddProductSizes(tx *sql.Tx, productId int, sizes []model.Size) error
 	UpdateProductSizes(productId int, removedSizes []int, addedSizes []model.Size) error
 	GetProductSizes(productId int) ([]model.Size, error)
 	CreateCollection(collection model.Collection) (int, error)
 	GetCollections() ([]model.Collection, error)
-	AddProductToCart(userId int, productId int, size string, amount int) error
-	RemoveProductFromCart(userId int, productId int) error
-	GetProductsInCart(userId int) ([]model.ProductInCart, error)
+	AddProductToCart(owner model.CartOwner, productId int, size string, amount int) error
+	RemoveProductFromCart(owner model.CartOwner, productId int, size string) error
+	UpdateCartLine(owner model.CartOwner, lineId int, amount int) error
+	RemoveCartLine(owner model.CartOwner, lineId int) error
+	GetProductsInCart(owner model.CartOwner) ([]model.ProductInCart, error)
 	AddProductToLiked(userId int, productId int) error
 	RemoveProductFromLiked(userId int, productId int) error
 	GetLikedProducts(userId int) ([]model.Product, error)
 	ChangeProductSizesAmount(sizesMap map[int]int) error
 	SearchProducts(userId int, userQuery string) ([]model.Product, error)
+	CreateProductPrice(price model.ProductPrice) (int, error)
+	GetProductPrices(productId int) ([]model.ProductPrice, error)
+	DeleteProductPrice(productId int, priceId int) error
 }
 
 type Orders interface {
-	CreateOrder(order model.Order) (model.Order, error)
+	CreateOrder(order model.Order, reservationExpiresAt time.Time) (model.Order, error)
 	GetUserOrders(userId int, status string, orderType string) ([]model.Order, error)
 	GetOrder(orderId int) (model.Order, error)
-	GetDeliveryOrders(status string) ([]model.Order, error)
-	GetPickupOrders(status string) ([]model.Order, error)
-	SetOrderStatus(orderId int, status string) error
+	GetDeliveryOrders(filter model.OrdersFilter) ([]model.Order, error)
+	GetPickupOrders(filter model.OrdersFilter) ([]model.Order, error)
+	SetOrderStatus(orderId int, fromStatus string, status string, changedBy int) error
+	GetOrderStatusHistory(orderId int) ([]model.OrderStatusChange, error)
+	CancelOrder(orderId int, fromStatus string, changedBy int, refund model.Refund) error
+	ReturnOrderedProducts(orderId int, fromStatus string, returnedProducts []model.ReturnedProduct, changedBy int, refund model.Refund) (bool, error)
+	SetPaymentId(orderId int, oldPaymentId string, paymentId string) error
+	GetOrderByPaymentId(paymentId string) (model.Order, error)
+	GetUnpaidOrders(createdBefore time.Time) ([]model.Order, error)
+	SetDeliveryId(orderId int, deliveryId string) error
+	GetOrderWeight(orderId int) (int, error)
+	GetShippedOrders() ([]model.Order, error)
+}
+
+type Reservations interface {
+	ReserveCart(userId int, sizes []SizeInfo, expiresAt time.Time) ([]model.StockReservation, error)
+	ReleaseExpiredReservations() (int64, error)
+}
+
+type Idempotency interface {
+	CreateIdempotencyKey(key model.IdempotencyKey) (model.IdempotencyKey, bool, error)
+	SaveIdempotencyResponse(id int, status int, body string) error
+	DeleteIdempotencyKey(id int) error
+	DeleteIdempotencyKeysBefore(createdBefore time.Time) (int64, error)
+}
+
+type ShopPoints interface {
+	CreateShopPoint(shopPoint model.ShopPoint) (int, error)
+	GetShopPoints(onlyActive bool) ([]model.ShopPoint, error)
+	GetShopPoint(shopPointId int) (model.ShopPoint, error)
+	UpdateShopPoint(shopPointId int, shopPoint model.ShopPoint) error
+	DeleteShopPoint(shopPointId int) error
+}
+
+type PromoCodes interface {
+	CreatePromoCode(promoCode model.PromoCode) (int, error)
+	GetPromoCodes() ([]model.PromoCode, error)
+	GetPromoCode(promoCodeId int) (model.PromoCode, error)
+	GetPromoCodeByCode(code string) (model.PromoCode, error)
+	UpdatePromoCode(promoCodeId int, promoCode model.PromoCode) error
+	DeletePromoCode(promoCodeId int) error
+	CountPromoCodeUsages(promoCodeId int, userId int) (int, int, error)
 }
 
 type ProductsMedia interface {
@@ -59,25 +164,73 @@ type ProductsMedia interface {
 type Reviews interface {
 	CreateReview(review model.Review) (int, error)
 	GetReviews(productId int) ([]model.Review, error)
-	DeleteReview(reviewId int) error
+	DeleteReview(reviewId int, userId int) error
 	UpdateReview(reviewId int, review model.Review) error
 	GetProductRating(productId int) (*float64, error)
 }
 
+type Notifications interface {
+	ClaimPendingNotifications(limit int, leaseUntil time.Time) ([]model.Notification, error)
+	MarkNotificationSent(notificationId int, recipient string, subject string) error
+	MarkNotificationFailed(notificationId int, status string, lastError string, nextAttemptAt time.Time) error
+	ResetNotification(notificationId int) error
+	GetNotifications(filter model.NotificationsFilter) ([]model.Notification, error)
+}
+
+type Addresses interface {
+	CreateAddress(address model.Address) (int, error)
+	GetAddresses(userId int) ([]model.Address, error)
+	GetAddress(userId int, addressId int) (model.Address, error)
+	UpdateAddress(userId int, addressId int, address model.Address) error
+	DeleteAddress(userId int, addressId int) error
+}
+
+type Refunds interface {
+	CreateRefund(refund model.Refund) error
+	GetPendingRefunds(orderId int) ([]model.Refund, error)
+	CompleteRefund(refundId int) error
+}
+
 type Repository struct {
 	Auth
+	RefreshTokens
+	UserTokens
+	TwoFactor
+	Identities
+	RateLimits
+	GuestCarts
 	Products
 	Orders
+	Refunds
+	Reservations
+	Idempotency
+	ShopPoints
+	PromoCodes
 	ProductsMedia
 	Reviews
+	Notifications
+	Addresses
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
 	return &Repository{
 		Auth:          NewAuthPostgres(db),
+		RefreshTokens: NewRefreshTokensPostgres(db),
+		UserTokens:    NewUserTokensPostgres(db),
+		TwoFactor:     NewTwoFactorPostgres(db),
+		Identities:    NewIdentitiesPostgres(db),
+		RateLimits:    NewRateLimitsPostgres(db),
+		GuestCarts:    NewGuestCartsPostgres(db),
 		Products:      NewProductsPostgres(db),
 		Orders:        NewOrdersPostgres(db),
+		Refunds:       NewRefundsPostgres(db),
+		Reservations:  NewReservationsPostgres(db),
+		Idempotency:   NewIdempotencyPostgres(db),
+		ShopPoints:    NewShopPointsPostgres(db),
+		PromoCodes:    NewPromoCodesPostgres(db),
 		ProductsMedia: NewProductsMediaPostgres(db),
 		Reviews:       NewReviewsPostgres(db),
+		Notifications: NewNotificationsPostgres(db),
+		Addresses:     NewAddressesPostgres(db),
 	}
 }
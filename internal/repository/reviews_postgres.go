@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
@@ -37,21 +38,34 @@ func (r *ReviewsPostgres) GetReviews(productId int) ([]model.Review, error) {
 	return reviews, nil
 }
 
-func (r *ReviewsPostgres) DeleteReview(reviewId int) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", reviewsTable)
-	_, err := r.db.Exec(query, reviewId)
+// DeleteReview removes the review of the given user, or any review when
+// userId is 0.
+func (r *ReviewsPostgres) DeleteReview(reviewId int, userId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND ($2 = 0 OR user_id = $2)", reviewsTable)
+	result, err := r.db.Exec(query, reviewId, userId)
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkRowsAffected(result)
 }
 
 func (r *ReviewsPostgres) UpdateReview(reviewId int, review model.Review) error {
 	query := fmt.Sprintf("UPDATE %s SET rating = $1, comment = $2 WHERE id = $3 AND user_id = $4", reviewsTable)
-	_, err := r.db.Exec(query, review.Rating, review.Comment, reviewId, review.UserID)
+	result, err := r.db.Exec(query, review.Rating, review.Comment, reviewId, review.UserID)
 	if err != nil {
 		return err
 	}
+	return checkRowsAffected(result)
+}
+
+func checkRowsAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
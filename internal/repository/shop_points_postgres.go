@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lib/pq"
+)
+
+const shopPointsTable = "shop_points"
+
+var ErrShopPointInUse = errors.New("shop point has orders, deactivate it instead")
+
+type ShopPointsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewShopPointsPostgres(db *sqlx.DB) *ShopPointsPostgres {
+	return &ShopPointsPostgres{db: db}
+}
+
+func (r *ShopPointsPostgres) CreateShopPoint(shopPoint model.ShopPoint) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (name, address, working_hours, latitude, longitude, is_active) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id", shopPointsTable)
+	row := r.db.QueryRow(query, shopPoint.Name, shopPoint.Address, shopPoint.WorkingHours, shopPoint.Latitude, shopPoint.Longitude, shopPoint.IsActive)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *ShopPointsPostgres) GetShopPoints(onlyActive bool) ([]model.ShopPoint, error) {
+	query := fmt.Sprintf("SELECT * FROM %s", shopPointsTable)
+	if onlyActive {
+		query += " WHERE is_active"
+	}
+	query += " ORDER BY id"
+	var shopPoints []model.ShopPoint
+	if err := r.db.Select(&shopPoints, query); err != nil {
+		return nil, err
+	}
+	return shopPoints, nil
+}
+
+func (r *ShopPointsPostgres) GetShopPoint(shopPointId int) (model.ShopPoint, error) {
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1", shopPointsTable)
+	var shopPoint model.ShopPoint
+	if err := r.db.Get(&shopPoint, query, shopPointId); err != nil {
+		return model.ShopPoint{}, err
+	}
+	return shopPoint, nil
+}
+
+func (r *ShopPointsPostgres) UpdateShopPoint(shopPointId int, shopPoint model.ShopPoint) error {
+	query := fmt.Sprintf("UPDATE %s SET name = $1, address = $2, working_hours = $3, latitude = $4, longitude = $5, is_active = $6 WHERE id = $7", shopPointsTable)
+	_, err := r.db.Exec(query, shopPoint.Name, shopPoint.Address, shopPoint.WorkingHours, shopPoint.Latitude, shopPoint.Longitude, shopPoint.IsActive, shopPointId)
+	return err
+}
+
+func (r *ShopPointsPostgres) DeleteShopPoint(shopPointId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", shopPointsTable)
+	_, err := r.db.Exec(query, shopPointId)
+	if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+		return ErrShopPointInUse
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const stockReservationsTable = "stock_reservations"
+
+type ReservationsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewReservationsPostgres(db *sqlx.DB) *ReservationsPostgres {
+	return &ReservationsPostgres{db: db}
+}
+
+func (r *ReservationsPostgres) ReserveCart(userId int, sizes []SizeInfo, expiresAt time.Time) ([]model.StockReservation, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if err := releaseUserReservations(tx, userId); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := reserveStock(tx, userId, 0, sizes, expiresAt); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	var reservations []model.StockReservation
+	query := fmt.Sprintf("SELECT * FROM %s WHERE user_id = $1 AND order_id IS NULL ORDER BY id", stockReservationsTable)
+	if err := r.db.Select(&reservations, query, userId); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+func (r *ReservationsPostgres) ReleaseExpiredReservations() (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE order_id IS NULL AND expires_at <= now()", stockReservationsTable)
+	result, err := r.db.Exec(query)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func releaseUserReservations(tx *sql.Tx, userId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND order_id IS NULL", stockReservationsTable)
+	_, err := tx.Exec(query, userId)
+	return err
+}
+
+func releaseOrderReservations(tx *sql.Tx, orderId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE order_id = $1", stockReservationsTable)
+	_, err := tx.Exec(query, orderId)
+	return err
+}
+
+func reserveStock(tx *sql.Tx, userId int, orderId int, sizes []SizeInfo, expiresAt time.Time) error {
+	sorted := make([]SizeInfo, len(sizes))
+	copy(sorted, sizes)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ProductID != sorted[j].ProductID {
+			return sorted[i].ProductID < sorted[j].ProductID
+		}
+		return sorted[i].SizeName < sorted[j].SizeName
+	})
+	for _, size := range sorted {
+		var amount int
+		query := fmt.Sprintf("SELECT amount FROM %s WHERE product_id = $1 AND name = $2 FOR UPDATE", sizesTable)
+		if err := tx.QueryRow(query, size.ProductID, size.SizeName).Scan(&amount); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotEnoughStock
+			}
+			return err
+		}
+		var reserved int
+		query = fmt.Sprintf("SELECT COALESCE(SUM(amount), 0) FROM %s WHERE product_id = $1 AND size = $2 AND expires_at > now()", stockReservationsTable)
+		if err := tx.QueryRow(query, size.ProductID, size.SizeName).Scan(&reserved); err != nil {
+			return err
+		}
+		if amount-reserved < size.Amount {
+			return ErrNotEnoughStock
+		}
+		query = fmt.Sprintf("INSERT INTO %s (user_id, product_id, size, amount, order_id, expires_at) VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6)", stockReservationsTable)
+		if _, err := tx.Exec(query, userId, size.ProductID, size.SizeName, size.Amount, orderId, expiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
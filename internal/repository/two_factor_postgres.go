@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const recoveryCodesTable = "recovery_codes"
+
+type TwoFactorPostgres struct {
+	db *sqlx.DB
+}
+
+func NewTwoFactorPostgres(db *sqlx.DB) *TwoFactorPostgres {
+	return &TwoFactorPostgres{db: db}
+}
+
+func (r *TwoFactorPostgres) GetTwoFactor(userId int) (model.TwoFactor, error) {
+	var twoFactor model.TwoFactor
+	query := fmt.Sprintf("SELECT totp_secret, totp_enabled_at, totp_last_step FROM %s WHERE id = $1 AND deleted_at IS NULL", usersTable)
+	if err := r.db.Get(&twoFactor, query, userId); err != nil {
+		return model.TwoFactor{}, err
+	}
+	return twoFactor, nil
+}
+
+// SetTOTPSecret stores a secret waiting for confirmation. It does nothing
+// once 2FA is enabled, so setup can't replace a working secret.
+func (r *TwoFactorPostgres) SetTOTPSecret(userId int, secret string) error {
+	query := fmt.Sprintf("UPDATE %s SET totp_secret = $1, totp_last_step = NULL WHERE id = $2 AND totp_enabled_at IS NULL", usersTable)
+	result, err := r.db.Exec(query, secret, userId)
+	if err != nil {
+		return err
+	}
+	return checkRowsAffected(result)
+}
+
+func (r *TwoFactorPostgres) EnableTwoFactor(userId int, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("UPDATE %s SET totp_enabled_at = now(), totp_last_step = $1 WHERE id = $2 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL", usersTable)
+	result, err := tx.Exec(query, step, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := checkRowsAffected(result); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userId, recoveryCodeHashes); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *TwoFactorPostgres) DisableTwoFactor(userId int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("UPDATE %s SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1", usersTable)
+	if _, err := tx.Exec(query, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userId, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records the time step of an accepted code and reports whether
+// it is newer than the last one, which rejects replayed codes.
+func (r *TwoFactorPostgres) UseTOTPStep(userId int, step int64) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET totp_last_step = $1 WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)", usersTable)
+	result, err := r.db.Exec(query, step, userId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *TwoFactorPostgres) ReplaceRecoveryCodes(userId int, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userId, recoveryCodeHashes); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *TwoFactorPostgres) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET used_at = now() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL", recoveryCodesTable)
+	result, err := r.db.Exec(query, userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userId int, recoveryCodeHashes []string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", recoveryCodesTable)
+	if _, err := tx.Exec(query, userId); err != nil {
+		return err
+	}
+	query = fmt.Sprintf("INSERT INTO %s (user_id, code_hash) VALUES ($1, $2)", recoveryCodesTable)
+	for _, codeHash := range recoveryCodeHashes {
+		if _, err := tx.Exec(query, userId, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const userTokensTable = "user_tokens"
+
+type UserTokensPostgres struct {
+	db *sqlx.DB
+}
+
+func NewUserTokensPostgres(db *sqlx.DB) *UserTokensPostgres {
+	return &UserTokensPostgres{db: db}
+}
+
+func (r *UserTokensPostgres) CreateUserToken(token model.UserToken) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id", userTokensTable)
+	row := r.db.QueryRow(query, token.UserID, token.Purpose, token.TokenHash, token.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *UserTokensPostgres) GetUserTokenByHash(tokenHash string) (model.UserToken, error) {
+	var token model.UserToken
+	query := fmt.Sprintf("SELECT * FROM %s WHERE token_hash = $1", userTokensTable)
+	if err := r.db.Get(&token, query, tokenHash); err != nil {
+		return model.UserToken{}, err
+	}
+	return token, nil
+}
+
+// UseUserToken marks an unused, unexpired token as used and reports whether
+// this call was the one that did it.
+func (r *UserTokensPostgres) UseUserToken(tokenId int) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET used_at = now() WHERE id = $1 AND used_at IS NULL AND expires_at > now()", userTokensTable)
+	result, err := r.db.Exec(query, tokenId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *UserTokensPostgres) InvalidateUserTokens(userId int, purpose string) error {
+	query := fmt.Sprintf("UPDATE %s SET used_at = now() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL", userTokensTable)
+	_, err := r.db.Exec(query, userId, purpose)
+	return err
+}
+
+func (r *UserTokensPostgres) DeleteExpiredUserTokens(expiredBefore time.Time) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE expires_at < $1", userTokensTable)
+	result, err := r.db.Exec(query, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+const maxAddressesPerUser = 20
+
+var (
+	ErrAddressNotFound     = errors.New("address not found")
+	ErrTooManyAddresses    = fmt.Errorf("no more than %d addresses can be saved", maxAddressesPerUser)
+	ErrAddressWithoutIndex = errors.New("address needs a valid postal index")
+)
+
+type AddressesService struct {
+	repo *repository.Repository
+}
+
+func NewAddressesService(repo *repository.Repository) *AddressesService {
+	return &AddressesService{repo: repo}
+}
+
+func (s *AddressesService) CreateAddress(userId int, address model.Address) (int, error) {
+	if !validPostalIndex(address.PostalIndex) {
+		return 0, ErrAddressWithoutIndex
+	}
+	addresses, err := s.repo.Addresses.GetAddresses(userId)
+	if err != nil {
+		return 0, err
+	}
+	if len(addresses) >= maxAddressesPerUser {
+		return 0, ErrTooManyAddresses
+	}
+	address.UserID = userId
+	return s.repo.Addresses.CreateAddress(address)
+}
+
+func (s *AddressesService) GetAddresses(userId int) ([]model.Address, error) {
+	return s.repo.Addresses.GetAddresses(userId)
+}
+
+func (s *AddressesService) GetAddress(userId int, addressId int) (model.Address, error) {
+	return getAddress(s.repo, userId, addressId)
+}
+
+// UpdateAddress can make an address the default one but not unset it; another
+// address has to become the default instead.
+func (s *AddressesService) UpdateAddress(userId int, addressId int, address model.Address) error {
+	if !validPostalIndex(address.PostalIndex) {
+		return ErrAddressWithoutIndex
+	}
+	err := s.repo.Addresses.UpdateAddress(userId, addressId, address)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAddressNotFound
+	}
+	return err
+}
+
+func (s *AddressesService) DeleteAddress(userId int, addressId int) error {
+	err := s.repo.Addresses.DeleteAddress(userId, addressId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAddressNotFound
+	}
+	return err
+}
+
+func getAddress(repo *repository.Repository, userId int, addressId int) (model.Address, error) {
+	address, err := repo.Addresses.GetAddress(userId, addressId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Address{}, ErrAddressNotFound
+	}
+	return address, err
+}
+
+func formatAddress(address model.Address) string {
+	return fmt.Sprintf("%s, %s", address.City, address.Street)
+}
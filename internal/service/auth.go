@@ -1,149 +1,222 @@
 package service
 
 import (
-	"crypto/sha1"
+	"context"
+	"database/sql"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 	"github.com/lavatee/dresscode_backend/internal/model"
 	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrTokenExpired       = errors.New("expired")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrWrongTokenType     = errors.New("wrong token type")
+	ErrEmailVerified      = errors.New("email is already verified")
+	ErrEmailNotVerified   = errors.New("email is not verified")
 )
 
 type AuthService struct {
-	repo *repository.Repository
+	repo          *repository.Repository
+	keys          *KeySet
+	mailer        Mailer
+	links         AuthLinks
+	twoFactor     TwoFactorPolicy
+	lockout       LoginLockoutPolicy
+	oauth         OAuthConfig
+	oauthClients  map[string]*OAuthClient
+	tokenVersions *tokenVersionCache
 }
 
 const (
-	salt       = "jd83420s32vv"
-	tokenKey   = "xks7jhc93n94hz"
 	accessTTL  = 15 * time.Minute
 	refreshTTL = 15 * 24 * time.Hour
+
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
 )
 
-func NewAuthService(repo *repository.Repository) *AuthService {
+func NewAuthService(repo *repository.Repository, keys *KeySet, mailer Mailer, links AuthLinks, twoFactor TwoFactorPolicy, lockout LoginLockoutPolicy, oauth OAuthConfig) *AuthService {
 	return &AuthService{
-		repo: repo,
+		repo:          repo,
+		keys:          keys,
+		mailer:        mailer,
+		links:         links,
+		twoFactor:     twoFactor,
+		lockout:       lockout,
+		oauth:         oauth,
+		oauthClients:  newOAuthClients(oauth),
+		tokenVersions: newTokenVersionCache(tokenVersionCacheTTL),
 	}
 }
 
 func (s *AuthService) CreateAdmin(name string, email string, password string) error {
-	passwordHash := s.hashPassword(password)
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
 	return s.repo.Auth.CreateAdmin(name, email, passwordHash)
 }
 
-func (s *AuthService) hashPassword(password string) string {
-	sha := sha1.New()
-	sha.Write([]byte(password))
-	return fmt.Sprintf("%x", sha.Sum([]byte(salt)))
+func (s *AuthService) SignUp(ctx context.Context, user model.User) (int, error) {
+	passwordHash, err := hashPassword(user.Password)
+	if err != nil {
+		return 0, err
+	}
+	user.Password = passwordHash
+	user.ID, err = s.repo.Auth.CreateUser(user)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		logrus.Errorf("error sending verification email to user %d: %s", user.ID, err.Error())
+	}
+	return user.ID, nil
 }
 
-func (s *AuthService) SignUp(user model.User) (int, error) {
-	user.Password = s.hashPassword(user.Password)
-	return s.repo.Auth.CreateUser(user)
+func (s *AuthService) ResendVerificationEmail(ctx context.Context, userId int) error {
+	user, err := s.repo.Auth.GetUser(userId)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailVerified
+	}
+	return s.sendVerificationEmail(ctx, user)
 }
 
-func (s *AuthService) SignIn(email, password string) (string, string, error) {
-	passwordHash := s.hashPassword(password)
-	userId, err := s.repo.Auth.SignIn(email, passwordHash)
+// SignIn checks the password unless the account is locked after too many
+// failures. With 2FA enabled the failure counter is kept until the second
+// factor passes, so knowing the password doesn't reset the lockout.
+func (s *AuthService) SignIn(email, password string, userAgent string, ip string) (SignInResult, error) {
+	account := loginAccount(email)
+	if err := s.checkLoginLock(account); err != nil {
+		return SignInResult{}, err
+	}
+	user, err := s.repo.Auth.GetUserByEmail(email)
 	if err != nil {
-		return "", "", err
+		if errors.Is(err, sql.ErrNoRows) {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			s.registerLoginFailure(account)
+			return SignInResult{}, ErrInvalidCredentials
+		}
+		return SignInResult{}, err
 	}
-	accessClaims := jwt.MapClaims{
-		"exp": time.Now().Add(accessTTL).Unix(),
-		"id":  userId,
+	ok, rehash := checkPassword(user.Password, password)
+	if !ok {
+		s.registerLoginFailure(account)
+		return SignInResult{}, ErrInvalidCredentials
 	}
-	refreshClaims := jwt.MapClaims{
-		"exp": time.Now().Add(refreshTTL).Unix(),
-		"id":  userId,
+	if rehash {
+		if passwordHash, err := hashPassword(password); err != nil {
+			logrus.Errorf("error rehashing password of user %d: %s", user.ID, err.Error())
+		} else if err := s.repo.Auth.UpdatePasswordHash(user.ID, passwordHash); err != nil {
+			logrus.Errorf("error saving rehashed password of user %d: %s", user.ID, err.Error())
+		}
 	}
-	access, err := s.NewToken(accessClaims)
-	if err != nil {
-		return "", "", err
+	if user.TwoFactorEnabledAt == nil {
+		s.resetLoginFailures(account)
 	}
-	refresh, err := s.NewToken(refreshClaims)
+	return s.completeSignIn(user, userAgent, ip)
+}
+
+// completeSignIn issues a token pair for a user who passed the first factor,
+// or a challenge token if 2FA is enabled.
+func (s *AuthService) completeSignIn(user model.User, userAgent string, ip string) (SignInResult, error) {
+	if user.TwoFactorEnabledAt != nil {
+		challenge, err := s.newUserToken(user.ID, twoFactorTokenType, twoFactorChallengeTTL)
+		if err != nil {
+			return SignInResult{}, err
+		}
+		return SignInResult{ChallengeToken: challenge}, nil
+	}
+	access, refresh, err := s.issueTokens(user.ID, uuid.NewString(), userAgent, ip)
 	if err != nil {
-		return "", "", err
+		return SignInResult{}, err
 	}
-	return access, refresh, nil
+	return SignInResult{UserID: user.ID, AccessToken: access, RefreshToken: refresh}, nil
 }
 
 func (s *AuthService) NewToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	stringToken, err := token.SignedString([]byte(tokenKey))
+	return s.keys.Sign(claims)
+}
+
+func (s *AuthService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+func (s *AuthService) Refresh(refreshToken string, userAgent string, ip string) (string, string, error) {
+	token, err := s.useRefreshToken(refreshToken)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	return stringToken, nil
+	return s.issueTokens(token.UserID, token.FamilyID, userAgent, ip)
 }
 
-func (s *AuthService) Refresh(refreshToken string) (string, string, error) {
-	parsedToken, err := jwt.ParseWithClaims(refreshToken, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid token")
-		}
-		return []byte(tokenKey), nil
-	})
+// ParseToken accepts only access tokens whose version matches the user's
+// current token version, so role changes and logout from all devices take
+// effect before the token expires.
+func (s *AuthService) ParseToken(token string) (jwt.MapClaims, error) {
+	claims, err := s.parseClaims(token, accessTokenType)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok && parsedToken.Valid {
-		accessClaims := jwt.MapClaims{
-			"exp": time.Now().Add(accessTTL).Unix(),
-			"id":  claims["id"],
-		}
-		refreshClaims := jwt.MapClaims{
-			"exp": time.Now().Add(refreshTTL).Unix(),
-			"id":  claims["id"],
-		}
-		access, err := s.NewToken(accessClaims)
-		if err != nil {
-			return "", "", err
-		}
-		refresh, err := s.NewToken(refreshClaims)
-		if err != nil {
-			return "", "", err
-		}
-		return access, refresh, nil
+	userId, ok := claims["id"].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	tokenVersion, ok := claims["ver"].(float64)
+	if !ok {
+		return nil, ErrTokenExpired
+	}
+	version, err := s.tokenVersions.get(int(userId), s.repo.Auth.GetTokenVersion)
+	if err != nil {
+		return nil, err
+	}
+	if int(tokenVersion) != version {
+		return nil, ErrTokenExpired
 	}
-	return "", "", errors.New("invalid token")
+	return claims, nil
 }
 
-func (s *AuthService) ParseToken(token string) (jwt.MapClaims, error) {
-	parsedToken, err := jwt.ParseWithClaims(token, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid token")
-		}
-		return []byte(tokenKey), nil
-	})
+func (s *AuthService) parseClaims(token string, tokenType string) (jwt.MapClaims, error) {
+	parsedToken, err := jwt.ParseWithClaims(token, jwt.MapClaims{}, s.keys.Keyfunc)
 	if err != nil {
-		return nil, errors.New("expired")
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrTokenExpired
+		}
+		return nil, ErrInvalidToken
+	}
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || !parsedToken.Valid {
+		return nil, ErrInvalidToken
 	}
-	if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok && parsedToken.Valid {
-		return claims, nil
+	if claims["typ"] != tokenType {
+		return nil, ErrWrongTokenType
 	}
-	return nil, errors.New("expired")
+	return claims, nil
 }
 
 func (s *AuthService) NewAdmin(thisAdminId, newAdminId int) error {
-	if !s.repo.Auth.IsAdmin(thisAdminId) {
-		return errors.New("user is not admin")
-	}
+	defer s.tokenVersions.invalidate(newAdminId)
 	return s.repo.Auth.NewAdmin(thisAdminId, newAdminId)
 }
 
 func (s *AuthService) NewBuyer(thisAdminId, newBuyerId int) error {
-	if !s.repo.Auth.IsAdmin(thisAdminId) {
-		return errors.New("user is not admin")
-	}
+	defer s.tokenVersions.invalidate(newBuyerId)
 	return s.repo.Auth.NewBuyer(thisAdminId, newBuyerId)
 }
 
 func (s *AuthService) RemoveBuyer(thisAdminId, buyerId int) error {
-	if !s.repo.Auth.IsAdmin(thisAdminId) {
-		return errors.New("user is not admin")
-	}
+	defer s.tokenVersions.invalidate(buyerId)
 	return s.repo.Auth.RemoveBuyer(thisAdminId, buyerId)
 }
 
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrCartLineNotFound  = errors.New("cart line not found")
+	ErrInvalidCartAmount = errors.New("amount must be positive")
+)
+
+// Cart is the owner's cart with its totals. Subtotal is counted at original
+// prices and Discount is the sale discount, so Total is what the order costs
+// before promo codes. Lines of removed sizes are not counted. Delivery is set
+// only when a postal index is known and the provider could quote it.
+type Cart struct {
+	Lines    []model.ProductInCart `json:"products_in_cart"`
+	Subtotal int                   `json:"subtotal"`
+	Discount int                   `json:"discount"`
+	Delivery *DeliveryQuote        `json:"delivery"`
+	Total    int                   `json:"total"`
+}
+
+func availableCartLines(productsInCart []model.ProductInCart) []model.ProductInCart {
+	available := make([]model.ProductInCart, 0, len(productsInCart))
+	for _, productInCart := range productsInCart {
+		if productInCart.Exists {
+			available = append(available, productInCart)
+		}
+	}
+	return available
+}
+
+func (s *ProductsService) AddProductToCart(owner model.CartOwner, productId int, size string, amount int) error {
+	if amount <= 0 {
+		return ErrInvalidCartAmount
+	}
+	return s.repo.Products.AddProductToCart(owner, productId, size, amount)
+}
+
+func (s *ProductsService) RemoveProductFromCart(owner model.CartOwner, productId int, size string) error {
+	return s.repo.Products.RemoveProductFromCart(owner, productId, size)
+}
+
+func (s *ProductsService) UpdateCartLine(owner model.CartOwner, lineId int, amount int) error {
+	if amount <= 0 {
+		return ErrInvalidCartAmount
+	}
+	err := s.repo.Products.UpdateCartLine(owner, lineId, amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCartLineNotFound
+	}
+	return err
+}
+
+func (s *ProductsService) RemoveCartLine(owner model.CartOwner, lineId int) error {
+	err := s.repo.Products.RemoveCartLine(owner, lineId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCartLineNotFound
+	}
+	return err
+}
+
+// GetProductsInCart returns the cart with totals. Delivery is estimated to the
+// given postal index or, when it is zero, to the user's default address.
+func (s *ProductsService) GetProductsInCart(ctx context.Context, owner model.CartOwner, index int) (Cart, error) {
+	if index != 0 && !validPostalIndex(index) {
+		return Cart{}, ErrInvalidPostalIndex
+	}
+	productsInCart, err := s.repo.Products.GetProductsInCart(owner)
+	if err != nil {
+		return Cart{}, err
+	}
+	cart := Cart{Lines: productsInCart}
+	available := availableCartLines(productsInCart)
+	for _, productInCart := range available {
+		cart.Subtotal += productInCart.OriginalPrice * productInCart.Amount
+		cart.Discount += (productInCart.OriginalPrice - productInCart.Price) * productInCart.Amount
+	}
+	cart.Total = cart.Subtotal - cart.Discount
+	if len(available) == 0 {
+		return cart, nil
+	}
+	if index == 0 && owner.UserID != 0 {
+		index, err = s.defaultPostalIndex(owner.UserID)
+		if err != nil {
+			return Cart{}, err
+		}
+	}
+	if index == 0 {
+		return cart, nil
+	}
+	quote, err := s.delivery.Quote(ctx, index, cartWeight(available))
+	if err != nil {
+		logrus.Warnf("Failed to estimate cart delivery to %d: %s", index, err.Error())
+		return cart, nil
+	}
+	cart.Delivery = &quote
+	cart.Total += quote.Price
+	return cart, nil
+}
+
+func (s *ProductsService) defaultPostalIndex(userId int) (int, error) {
+	addresses, err := s.repo.Addresses.GetAddresses(userId)
+	if err != nil {
+		return 0, err
+	}
+	for _, address := range addresses {
+		if address.IsDefault {
+			return address.PostalIndex, nil
+		}
+	}
+	return 0, nil
+}
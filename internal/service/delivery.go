@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	TrackingCreated   = "created"
+	TrackingInTransit = "in_transit"
+	TrackingDelivered = "delivered"
+)
+
+var (
+	ErrInvalidPostalIndex  = errors.New("invalid postal index")
+	ErrShipmentNotAllowed  = errors.New("shipment can only be created for a paid delivery order")
+	ErrShipmentNotFound    = errors.New("shipment not found")
+	ErrShipmentAlreadySent = errors.New("shipment is already registered for this order")
+)
+
+type DeliveryQuote struct {
+	Price   int `json:"price"`
+	MinDays int `json:"min_days"`
+	MaxDays int `json:"max_days"`
+}
+
+type ShipmentRequest struct {
+	OrderID        int
+	RecipientName  string
+	RecipientEmail string
+	Address        string
+	Index          int
+	WeightGrams    int
+	DeclaredValue  int
+}
+
+type DeliveryProvider interface {
+	Quote(ctx context.Context, toIndex int, weightGrams int) (DeliveryQuote, error)
+	CreateShipment(ctx context.Context, request ShipmentRequest) (string, error)
+	GetTrackingStatus(ctx context.Context, shipmentId string) (string, error)
+}
+
+func validPostalIndex(index int) bool {
+	return index >= 100000 && index <= 999999
+}
+
+func cartWeight(productsInCart []model.ProductInCart) int {
+	weight := 0
+	for _, productInCart := range productsInCart {
+		weight += productInCart.Weight * productInCart.Amount
+	}
+	return weight
+}
+
+type DeliveryService struct {
+	repo     *repository.Repository
+	provider DeliveryProvider
+}
+
+func NewDeliveryService(repo *repository.Repository, provider DeliveryProvider) *DeliveryService {
+	return &DeliveryService{
+		repo:     repo,
+		provider: provider,
+	}
+}
+
+func (s *DeliveryService) Quote(ctx context.Context, userId int, index int) (DeliveryQuote, error) {
+	if !validPostalIndex(index) {
+		return DeliveryQuote{}, ErrInvalidPostalIndex
+	}
+	productsInCart, err := s.repo.Products.GetProductsInCart(model.CartOwner{UserID: userId})
+	if err != nil {
+		return DeliveryQuote{}, err
+	}
+	if len(productsInCart) == 0 {
+		return DeliveryQuote{}, ErrEmptyCart
+	}
+	return s.provider.Quote(ctx, index, cartWeight(productsInCart))
+}
+
+func (s *DeliveryService) CreateShipment(ctx context.Context, orderId int) (string, error) {
+	order, err := s.repo.Orders.GetOrder(orderId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrOrderNotFound
+		}
+		return "", err
+	}
+	if order.Type != DeliveryOrderType || order.Status != CreatedOrderStatus {
+		return "", ErrShipmentNotAllowed
+	}
+	if order.DeliveryID != nil && *order.DeliveryID != "" {
+		return "", ErrShipmentAlreadySent
+	}
+	weight, err := s.repo.Orders.GetOrderWeight(orderId)
+	if err != nil {
+		return "", err
+	}
+	shipmentId, err := s.provider.CreateShipment(ctx, ShipmentRequest{
+		OrderID:        order.ID,
+		RecipientName:  order.UserName,
+		RecipientEmail: order.UserEmail,
+		Address:        order.DeliveryAddress,
+		Index:          order.DeliveryIndex,
+		WeightGrams:    weight,
+		DeclaredValue:  order.OrderPrice,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.Orders.SetDeliveryId(orderId, shipmentId); err != nil {
+		return "", err
+	}
+	return shipmentId, nil
+}
+
+func (s *DeliveryService) SyncShipments(ctx context.Context) error {
+	orders, err := s.repo.Orders.GetShippedOrders()
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if order.DeliveryID == nil {
+			continue
+		}
+		status, err := s.provider.GetTrackingStatus(ctx, *order.DeliveryID)
+		if err != nil {
+			logrus.Errorf("error getting tracking status of order %d: %s", order.ID, err.Error())
+			continue
+		}
+		for _, next := range trackingOrderStatuses(order.Status, status) {
+			if err := checkOrderTransition(order.Type, order.Status, next, systemActor); err != nil {
+				logrus.Errorf("error advancing order %d: %s", order.ID, err.Error())
+				break
+			}
+			if err := s.repo.Orders.SetOrderStatus(order.ID, order.Status, next, 0); err != nil {
+				logrus.Errorf("error advancing order %d: %s", order.ID, err.Error())
+				break
+			}
+			order.Status = next
+		}
+	}
+	return nil
+}
+
+func trackingOrderStatuses(orderStatus string, trackingStatus string) []string {
+	switch trackingStatus {
+	case TrackingInTransit:
+		if orderStatus == CreatedOrderStatus {
+			return []string{SentToCustomerOrderStatus}
+		}
+	case TrackingDelivered:
+		if orderStatus == CreatedOrderStatus {
+			return []string{SentToCustomerOrderStatus, DeliveredToCustomerOrderStatus}
+		}
+		if orderStatus == SentToCustomerOrderStatus {
+			return []string{DeliveredToCustomerOrderStatus}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type CDEKConfig struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+	TariffCode   int
+	FromIndex    int
+}
+
+type CDEKProvider struct {
+	config CDEKConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func NewCDEKProvider(config CDEKConfig) *CDEKProvider {
+	return &CDEKProvider{
+		config: config,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type cdekLocation struct {
+	PostalCode string `json:"postal_code"`
+	Address    string `json:"address,omitempty"`
+}
+
+type cdekPackage struct {
+	Number string `json:"number,omitempty"`
+	Weight int    `json:"weight"`
+}
+
+func (p *CDEKProvider) Quote(ctx context.Context, toIndex int, weightGrams int) (DeliveryQuote, error) {
+	body := map[string]interface{}{
+		"tariff_code":   p.config.TariffCode,
+		"from_location": cdekLocation{PostalCode: strconv.Itoa(p.config.FromIndex)},
+		"to_location":   cdekLocation{PostalCode: strconv.Itoa(toIndex)},
+		"packages":      []cdekPackage{{Weight: weightGrams}},
+	}
+	var result struct {
+		DeliverySum float64 `json:"delivery_sum"`
+		PeriodMin   int     `json:"period_min"`
+		PeriodMax   int     `json:"period_max"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v2/calculator/tariff", body, &result); err != nil {
+		return DeliveryQuote{}, err
+	}
+	return DeliveryQuote{
+		Price:   int(result.DeliverySum + 0.5),
+		MinDays: result.PeriodMin,
+		MaxDays: result.PeriodMax,
+	}, nil
+}
+
+func (p *CDEKProvider) CreateShipment(ctx context.Context, request ShipmentRequest) (string, error) {
+	body := map[string]interface{}{
+		"number":        strconv.Itoa(request.OrderID),
+		"tariff_code":   p.config.TariffCode,
+		"from_location": cdekLocation{PostalCode: strconv.Itoa(p.config.FromIndex)},
+		"to_location":   cdekLocation{PostalCode: strconv.Itoa(request.Index), Address: request.Address},
+		"recipient": map[string]interface{}{
+			"name":  request.RecipientName,
+			"email": request.RecipientEmail,
+		},
+		"packages": []cdekPackage{{Number: strconv.Itoa(request.OrderID), Weight: request.WeightGrams}},
+	}
+	var result struct {
+		Entity struct {
+			UUID string `json:"uuid"`
+		} `json:"entity"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v2/orders", body, &result); err != nil {
+		return "", err
+	}
+	return result.Entity.UUID, nil
+}
+
+func (p *CDEKProvider) GetTrackingStatus(ctx context.Context, shipmentId string) (string, error) {
+	var result struct {
+		Entity struct {
+			Statuses []struct {
+				Code string `json:"code"`
+			} `json:"statuses"`
+		} `json:"entity"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/v2/orders/"+shipmentId, nil, &result); err != nil {
+		return "", err
+	}
+	if len(result.Entity.Statuses) == 0 {
+		return TrackingCreated, nil
+	}
+	switch result.Entity.Statuses[0].Code {
+	case "CREATED", "ACCEPTED":
+		return TrackingCreated, nil
+	case "DELIVERED":
+		return TrackingDelivered, nil
+	default:
+		return TrackingInTransit, nil
+	}
+}
+
+func (p *CDEKProvider) accessToken(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && time.Now().Before(p.tokenExpiry) {
+		return p.token, nil
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.URL+"/v2/oauth/token", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("cdek: auth: %d", resp.StatusCode)
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	p.token = result.AccessToken
+	p.tokenExpiry = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return p.token, nil
+}
+
+func (p *CDEKProvider) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	token, err := p.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.config.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("cdek: %s %s: %d %s", method, path, resp.StatusCode, string(data))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
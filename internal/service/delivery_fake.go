@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var fakeTrackingSteps = []string{TrackingCreated, TrackingInTransit, TrackingDelivered}
+
+type FakeDeliveryProvider struct {
+	mu        sync.Mutex
+	shipments map[string]int
+}
+
+func NewFakeDeliveryProvider() *FakeDeliveryProvider {
+	return &FakeDeliveryProvider{
+		shipments: make(map[string]int),
+	}
+}
+
+func (p *FakeDeliveryProvider) Quote(ctx context.Context, toIndex int, weightGrams int) (DeliveryQuote, error) {
+	kilograms := (weightGrams + 999) / 1000
+	if kilograms == 0 {
+		kilograms = 1
+	}
+	zone := toIndex / 100000
+	return DeliveryQuote{
+		Price:   300 + 50*kilograms + 20*zone,
+		MinDays: 1 + zone/2,
+		MaxDays: 3 + zone/2,
+	}, nil
+}
+
+func (p *FakeDeliveryProvider) CreateShipment(ctx context.Context, request ShipmentRequest) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	shipmentId := fmt.Sprintf("fake-shipment-%d", request.OrderID)
+	p.shipments[shipmentId] = 0
+	return shipmentId, nil
+}
+
+func (p *FakeDeliveryProvider) GetTrackingStatus(ctx context.Context, shipmentId string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	step := p.shipments[shipmentId]
+	if step < len(fakeTrackingSteps)-1 {
+		p.shipments[shipmentId] = step + 1
+	}
+	return fakeTrackingSteps[step], nil
+}
+
+func (p *FakeDeliveryProvider) SetTrackingStatus(shipmentId string, status string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for step, trackingStatus := range fakeTrackingSteps {
+		if trackingStatus == status {
+			p.shipments[shipmentId] = step
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown tracking status %s", status)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const guestCartTokenType = "guest_cart"
+
+var ErrInvalidCartToken = errors.New("invalid cart token")
+
+// GuestCartsService keeps carts of anonymous visitors. A guest cart is known
+// only by the id in its signed token, and its lines are stored once the first
+// product is added.
+type GuestCartsService struct {
+	repo *repository.Repository
+	keys *KeySet
+	ttl  time.Duration
+}
+
+func NewGuestCartsService(repo *repository.Repository, keys *KeySet, ttl time.Duration) *GuestCartsService {
+	return &GuestCartsService{
+		repo: repo,
+		keys: keys,
+		ttl:  ttl,
+	}
+}
+
+// IssueGuestCartToken signs a fresh token for the guest cart, starting a new
+// cart when guestCartId is empty, so the token lives as long as the cart is used.
+func (s *GuestCartsService) IssueGuestCartToken(guestCartId string) (string, string, error) {
+	if guestCartId == "" {
+		guestCartId = uuid.NewString()
+	}
+	token, err := s.keys.Sign(jwt.MapClaims{
+		"exp": time.Now().Add(s.ttl).Unix(),
+		"typ": guestCartTokenType,
+		"cid": guestCartId,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return guestCartId, token, nil
+}
+
+func (s *GuestCartsService) ParseGuestCartToken(token string) (string, error) {
+	parsedToken, err := jwt.ParseWithClaims(token, jwt.MapClaims{}, s.keys.Keyfunc)
+	if err != nil || !parsedToken.Valid {
+		return "", ErrInvalidCartToken
+	}
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || claims["typ"] != guestCartTokenType {
+		return "", ErrInvalidCartToken
+	}
+	guestCartId, _ := claims["cid"].(string)
+	if _, err := uuid.Parse(guestCartId); err != nil {
+		return "", ErrInvalidCartToken
+	}
+	return guestCartId, nil
+}
+
+func (s *GuestCartsService) GuestCartTTL() time.Duration {
+	return s.ttl
+}
+
+func (s *GuestCartsService) MergeGuestCart(guestCartId string, userId int) error {
+	return s.repo.GuestCarts.MergeGuestCart(guestCartId, userId)
+}
+
+func (s *GuestCartsService) DeleteExpiredGuestCarts(ctx context.Context) error {
+	deleted, err := s.repo.GuestCarts.DeleteExpiredGuestCarts(time.Now().Add(-s.ttl))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logrus.Infof("Deleted %d expired guest carts", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrIdempotencyKeyReused   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInFlight = errors.New("request with this idempotency key is still being processed")
+)
+
+type IdempotencyService struct {
+	repo *repository.Repository
+	ttl  time.Duration
+}
+
+func NewIdempotencyService(repo *repository.Repository, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+func requestHash(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (s *IdempotencyService) BeginRequest(scope string, key string, method string, path string, body []byte) (model.IdempotencyKey, bool, error) {
+	record, created, err := s.repo.Idempotency.CreateIdempotencyKey(model.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		Method:      method,
+		Path:        path,
+		RequestHash: requestHash(method, path, body),
+	})
+	if err != nil {
+		return model.IdempotencyKey{}, false, err
+	}
+	if created {
+		return record, false, nil
+	}
+	if record.RequestHash != requestHash(method, path, body) {
+		return model.IdempotencyKey{}, false, ErrIdempotencyKeyReused
+	}
+	if record.ResponseStatus == nil {
+		return model.IdempotencyKey{}, false, ErrIdempotencyKeyInFlight
+	}
+	return record, true, nil
+}
+
+func (s *IdempotencyService) CompleteRequest(id int, status int, body string) error {
+	return s.repo.Idempotency.SaveIdempotencyResponse(id, status, body)
+}
+
+func (s *IdempotencyService) AbortRequest(id int) error {
+	return s.repo.Idempotency.DeleteIdempotencyKey(id)
+}
+
+func (s *IdempotencyService) DeleteExpiredKeys(ctx context.Context) error {
+	deleted, err := s.repo.Idempotency.DeleteIdempotencyKeysBefore(time.Now().Add(-s.ttl))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logrus.Infof("Deleted %d expired idempotency keys", deleted)
+	}
+	return nil
+}
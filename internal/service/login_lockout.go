@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxLockoutDoublings caps the shift in lockDuration so it can't overflow.
+const maxLockoutDoublings = 20
+
+var ErrTooManyLoginAttempts = errors.New("too many failed sign in attempts, try again later")
+
+// LoginLockoutPolicy locks sign in for an account after Threshold failed
+// attempts in a row. The lock starts at LockFor and doubles with every
+// further failure up to MaxLockFor. Failures are forgotten after a successful
+// sign in or ResetAfter without attempts.
+type LoginLockoutPolicy struct {
+	Threshold  int
+	LockFor    time.Duration
+	MaxLockFor time.Duration
+	ResetAfter time.Duration
+}
+
+func (p LoginLockoutPolicy) lockDuration(failures int) time.Duration {
+	doublings := failures - p.Threshold
+	if doublings > maxLockoutDoublings {
+		doublings = maxLockoutDoublings
+	}
+	lock := p.LockFor << doublings
+	if lock > p.MaxLockFor {
+		return p.MaxLockFor
+	}
+	return lock
+}
+
+// loginAccount is the lockout key for an email. Unknown emails are counted
+// too, so the lockout doesn't reveal which accounts exist.
+func loginAccount(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (s *AuthService) checkLoginLock(account string) error {
+	if s.lockout.Threshold <= 0 {
+		return nil
+	}
+	lockedUntil, err := s.repo.RateLimits.GetLoginLockedUntil(account)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if lockedUntil != nil {
+		if wait := time.Until(*lockedUntil); wait > 0 {
+			return &RetryAfterError{Err: ErrTooManyLoginAttempts, RetryAfter: wait}
+		}
+	}
+	return nil
+}
+
+// registerLoginFailure only logs its errors, since the caller already has the
+// error to return.
+func (s *AuthService) registerLoginFailure(account string) {
+	if s.lockout.Threshold <= 0 {
+		return
+	}
+	failures, err := s.repo.RateLimits.RegisterLoginFailure(account)
+	if err != nil {
+		logrus.Errorf("error registering failed sign in: %s", err.Error())
+		return
+	}
+	if failures < s.lockout.Threshold {
+		return
+	}
+	lock := s.lockout.lockDuration(failures)
+	if err := s.repo.RateLimits.LockLogin(account, time.Now().Add(lock)); err != nil {
+		logrus.Errorf("error locking sign in: %s", err.Error())
+		return
+	}
+	logrus.Warnf("Sign in locked for %s after %d failed attempts", lock, failures)
+}
+
+func (s *AuthService) resetLoginFailures(account string) {
+	if s.lockout.Threshold <= 0 {
+		return
+	}
+	if err := s.repo.RateLimits.ResetLoginFailures(account); err != nil {
+		logrus.Errorf("error resetting failed sign ins: %s", err.Error())
+	}
+}
+
+func (s *AuthService) DeleteStaleLoginFailures(ctx context.Context) error {
+	deleted, err := s.repo.RateLimits.DeleteStaleLoginFailures(time.Now().Add(-s.lockout.ResetAfter))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logrus.Infof("Deleted %d stale failed sign in counters", deleted)
+	}
+	return nil
+}
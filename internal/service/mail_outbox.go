@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// outboxKeep is how many of the latest emails OutboxMailer keeps in memory.
+const outboxKeep = 100
+
+// OutboxMailer keeps the latest sent emails in memory and, when dir is set,
+// also writes them there as .eml files, so local development and tests need no
+// SMTP server.
+type OutboxMailer struct {
+	from   string
+	dir    string
+	mu     sync.Mutex
+	sent   int
+	emails []Email
+}
+
+func NewOutboxMailer(from string, dir string) *OutboxMailer {
+	return &OutboxMailer{
+		from: from,
+		dir:  dir,
+	}
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, email Email) error {
+	message, err := buildMessage(m.from, email)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent++
+	m.emails = append(m.emails, email)
+	if len(m.emails) > outboxKeep {
+		m.emails = append(m.emails[:0], m.emails[len(m.emails)-outboxKeep:]...)
+	}
+	if m.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	fileName := filepath.Join(m.dir, fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102T150405.000000000"), m.sent))
+	if err := os.WriteFile(fileName, message, 0o644); err != nil {
+		return err
+	}
+	logrus.Infof("Email %q to %s written to %s", email.Subject, email.To, fileName)
+	return nil
+}
+
+func (m *OutboxMailer) Emails() []Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	emails := make([]Email, len(m.emails))
+	copy(emails, m.emails)
+	return emails
+}
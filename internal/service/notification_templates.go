@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+const (
+	OrderCreatedEvent       = "order_created"
+	OrderStatusChangedEvent = "order_status_changed"
+
+	defaultLocale = "ru"
+)
+
+var (
+	//go:embed templates
+	templatesFS embed.FS
+
+	notificationEvents = []string{OrderCreatedEvent, OrderStatusChangedEvent}
+	supportedLocales   = []string{"ru", "en"}
+
+	orderStatusNames = map[string]map[string]string{
+		"ru": {
+			PendingOrderStatus:             "ожидает оплаты",
+			CreatedOrderStatus:             "оплачен",
+			DeliveredToShopOrderStatus:     "доставлен в магазин",
+			IssuedOrderStatus:              "выдан",
+			SentToCustomerOrderStatus:      "передан в доставку",
+			DeliveredToCustomerOrderStatus: "доставлен",
+			CancelledOrderStatus:           "отменён",
+			ReturnedOrderStatus:            "возвращён",
+		},
+		"en": {
+			PendingOrderStatus:             "awaiting payment",
+			CreatedOrderStatus:             "paid",
+			DeliveredToShopOrderStatus:     "ready for pickup",
+			IssuedOrderStatus:              "picked up",
+			SentToCustomerOrderStatus:      "shipped",
+			DeliveredToCustomerOrderStatus: "delivered",
+			CancelledOrderStatus:           "cancelled",
+			ReturnedOrderStatus:            "returned",
+		},
+	}
+)
+
+type OrderNotificationData struct {
+	Name       string
+	Order      model.Order
+	Total      int
+	Status     string
+	FromStatus string
+	OrderURL   string
+}
+
+// NotificationTemplates renders notification emails from the embedded
+// templates. Every event has a text template defining "subject" and "text"
+// and an HTML template for each supported locale.
+type NotificationTemplates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func NewNotificationTemplates() (*NotificationTemplates, error) {
+	templates := &NotificationTemplates{
+		text: make(map[string]*texttemplate.Template),
+		html: make(map[string]*htmltemplate.Template),
+	}
+	for _, event := range notificationEvents {
+		for _, locale := range supportedLocales {
+			name := event + "." + locale
+			textTemplate, err := texttemplate.ParseFS(templatesFS, "templates/"+name+".txt")
+			if err != nil {
+				return nil, err
+			}
+			if textTemplate.Lookup("subject") == nil || textTemplate.Lookup("text") == nil {
+				return nil, fmt.Errorf("template %s.txt must define subject and text", name)
+			}
+			htmlTemplate, err := htmltemplate.ParseFS(templatesFS, "templates/"+name+".html")
+			if err != nil {
+				return nil, err
+			}
+			templates.text[name] = textTemplate
+			templates.html[name] = htmlTemplate
+		}
+	}
+	return templates, nil
+}
+
+func (t *NotificationTemplates) Render(event string, locale string, data interface{}) (Email, error) {
+	name := event + "." + notificationLocale(locale)
+	textTemplate, ok := t.text[name]
+	if !ok {
+		return Email{}, fmt.Errorf("no template for notification event %s", event)
+	}
+	var subject, text, html bytes.Buffer
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Email{}, err
+	}
+	if err := textTemplate.ExecuteTemplate(&text, "text", data); err != nil {
+		return Email{}, err
+	}
+	if err := t.html[name].Execute(&html, data); err != nil {
+		return Email{}, err
+	}
+	return Email{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func notificationLocale(locale string) string {
+	for _, supportedLocale := range supportedLocales {
+		if locale == supportedLocale {
+			return locale
+		}
+	}
+	return defaultLocale
+}
+
+func orderStatusName(locale string, status string) string {
+	if name, ok := orderStatusNames[notificationLocale(locale)][status]; ok {
+		return name
+	}
+	return status
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+func testNotificationData(locale string) OrderNotificationData {
+	return OrderNotificationData{
+		Name: `Anna <script>alert("x")</script>`,
+		Order: model.Order{
+			ID:            42,
+			OrderPrice:    5000,
+			DeliveryPrice: 300,
+			OrderedProducts: []model.OrderedProduct{
+				{ProductName: "Dress & Co", Size: "M", Amount: 2, Price: 2500},
+			},
+		},
+		Total:      5300,
+		Status:     orderStatusName(locale, CreatedOrderStatus),
+		FromStatus: orderStatusName(locale, PendingOrderStatus),
+		OrderURL:   "http://localhost:3000/orders/42",
+	}
+}
+
+func TestNotificationTemplatesRender(t *testing.T) {
+	templates, err := NewNotificationTemplates()
+	if err != nil {
+		t.Fatalf("NewNotificationTemplates: %s", err)
+	}
+	tests := []struct {
+		event   string
+		locale  string
+		subject string
+		text    []string
+	}{
+		{OrderCreatedEvent, "ru", "Заказ №42 оформлен", []string{"Dress & Co, размер M, 2 шт.", "Доставка: 300 ₽", "Итого: 5300 ₽", "Статус: оплачен"}},
+		{OrderCreatedEvent, "en", "Order #42 placed", []string{"Dress & Co, size M, 2 pcs", "Delivery: 300 ₽", "Total: 5300 ₽", "Status: paid"}},
+		{OrderStatusChangedEvent, "ru", "Заказ №42: оплачен", []string{"ожидает оплаты", "оплачен"}},
+		{OrderStatusChangedEvent, "en", "Order #42: paid", []string{`from "awaiting payment" to "paid"`}},
+	}
+	for _, test := range tests {
+		t.Run(test.event+"."+test.locale, func(t *testing.T) {
+			data := testNotificationData(test.locale)
+			email, err := templates.Render(test.event, test.locale, data)
+			if err != nil {
+				t.Fatalf("Render: %s", err)
+			}
+			if email.Subject != test.subject {
+				t.Errorf("subject = %q, want %q", email.Subject, test.subject)
+			}
+			for _, want := range append(test.text, data.Name, data.OrderURL) {
+				if !strings.Contains(email.Text, want) {
+					t.Errorf("text doesn't contain %q:\n%s", want, email.Text)
+				}
+			}
+			if !strings.Contains(email.HTML, `lang="`+test.locale+`"`) {
+				t.Errorf("HTML isn't in %s:\n%s", test.locale, email.HTML)
+			}
+			if strings.Contains(email.HTML, "<script>") || !strings.Contains(email.HTML, "Anna &lt;script&gt;") {
+				t.Errorf("HTML doesn't escape the user name:\n%s", email.HTML)
+			}
+			if strings.Contains(email.HTML, "Dress & Co") {
+				t.Errorf("HTML doesn't escape the product name:\n%s", email.HTML)
+			}
+		})
+	}
+}
+
+func TestNotificationTemplatesLocaleFallback(t *testing.T) {
+	templates, err := NewNotificationTemplates()
+	if err != nil {
+		t.Fatalf("NewNotificationTemplates: %s", err)
+	}
+	for _, locale := range []string{"de", ""} {
+		email, err := templates.Render(OrderCreatedEvent, locale, testNotificationData(locale))
+		if err != nil {
+			t.Fatalf("Render in %q: %s", locale, err)
+		}
+		want, err := templates.Render(OrderCreatedEvent, defaultLocale, testNotificationData(defaultLocale))
+		if err != nil {
+			t.Fatalf("Render in %s: %s", defaultLocale, err)
+		}
+		if email != want {
+			t.Errorf("email in %q = %+v, want the %s one %+v", locale, email, defaultLocale, want)
+		}
+	}
+	if _, err := templates.Render("unknown_event", "en", testNotificationData("en")); err == nil {
+		t.Error("Render succeeded for an unknown event")
+	}
+}
+
+func TestNotificationRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, notificationRetryBaseDelay},
+		{1, notificationRetryBaseDelay},
+		{2, 2 * notificationRetryBaseDelay},
+		{3, 4 * notificationRetryBaseDelay},
+		{6, 32 * notificationRetryBaseDelay},
+		{7, notificationRetryMaxDelay},
+		{100, notificationRetryMaxDelay},
+	}
+	for _, test := range tests {
+		if delay := notificationRetryDelay(test.attempts); delay != test.want {
+			t.Errorf("notificationRetryDelay(%d) = %s, want %s", test.attempts, delay, test.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+
+	notificationsBatchSize     = 20
+	notificationLease          = 5 * time.Minute
+	notificationRetryBaseDelay = time.Minute
+	notificationRetryMaxDelay  = time.Hour
+)
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type NotificationsService struct {
+	repo        *repository.Repository
+	mailer      Mailer
+	templates   *NotificationTemplates
+	ordersURL   string
+	maxAttempts int
+}
+
+func NewNotificationsService(repo *repository.Repository, mailer Mailer, templates *NotificationTemplates, ordersURL string, maxAttempts int) *NotificationsService {
+	return &NotificationsService{
+		repo:        repo,
+		mailer:      mailer,
+		templates:   templates,
+		ordersURL:   strings.TrimRight(ordersURL, "/"),
+		maxAttempts: maxAttempts,
+	}
+}
+
+// SendPendingNotifications sends notifications that are due. Failed sends are
+// retried with exponential backoff until maxAttempts is reached.
+func (s *NotificationsService) SendPendingNotifications(ctx context.Context) error {
+	notifications, err := s.repo.Notifications.ClaimPendingNotifications(notificationsBatchSize, time.Now().Add(notificationLease))
+	if err != nil {
+		return err
+	}
+	for _, notification := range notifications {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		email, err := s.renderNotification(notification)
+		if err == nil {
+			err = s.mailer.Send(ctx, email)
+		}
+		if err != nil {
+			if err := s.failNotification(notification, err); err != nil {
+				logrus.Errorf("error saving failed attempt of notification %d: %s", notification.ID, err.Error())
+			}
+			continue
+		}
+		if err := s.repo.Notifications.MarkNotificationSent(notification.ID, email.To, email.Subject); err != nil {
+			logrus.Errorf("error marking notification %d as sent: %s", notification.ID, err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *NotificationsService) renderNotification(notification model.Notification) (Email, error) {
+	if notification.OrderID == nil || notification.ToStatus == nil {
+		return Email{}, fmt.Errorf("notification %d has no order", notification.ID)
+	}
+	order, err := s.repo.Orders.GetOrder(*notification.OrderID)
+	if err != nil {
+		return Email{}, err
+	}
+	user, err := s.repo.Auth.GetUser(notification.UserID)
+	if err != nil {
+		return Email{}, err
+	}
+	data := OrderNotificationData{
+		Name:     user.Name,
+		Order:    order,
+		Total:    order.OrderPrice + order.DeliveryPrice,
+		Status:   orderStatusName(user.Locale, *notification.ToStatus),
+		OrderURL: fmt.Sprintf("%s/%d", s.ordersURL, order.ID),
+	}
+	if notification.FromStatus != nil {
+		data.FromStatus = orderStatusName(user.Locale, *notification.FromStatus)
+	}
+	email, err := s.templates.Render(notification.Event, user.Locale, data)
+	if err != nil {
+		return Email{}, err
+	}
+	email.To = user.Email
+	return email, nil
+}
+
+func (s *NotificationsService) failNotification(notification model.Notification, sendErr error) error {
+	attempts := notification.Attempts + 1
+	status := NotificationPending
+	if attempts >= s.maxAttempts {
+		status = NotificationFailed
+	}
+	logrus.Warnf("Notification %d attempt %d failed: %s", notification.ID, attempts, sendErr.Error())
+	return s.repo.Notifications.MarkNotificationFailed(notification.ID, status, sendErr.Error(), time.Now().Add(notificationRetryDelay(attempts)))
+}
+
+func notificationRetryDelay(attempts int) time.Duration {
+	delay := notificationRetryBaseDelay
+	for i := 1; i < attempts && delay < notificationRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > notificationRetryMaxDelay {
+		return notificationRetryMaxDelay
+	}
+	return delay
+}
+
+func (s *NotificationsService) GetNotifications(filter model.NotificationsFilter) ([]model.Notification, error) {
+	return s.repo.Notifications.GetNotifications(filter)
+}
+
+// ResendNotification queues a notification again with a fresh retry budget,
+// whether it was sent or gave up.
+func (s *NotificationsService) ResendNotification(notificationId int) error {
+	err := s.repo.Notifications.ResetNotification(notificationId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotificationNotFound
+	}
+	return err
+}
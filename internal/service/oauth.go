@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	oauthStateTokenType = "oauth_state"
+	oauthStateTTL       = 10 * time.Minute
+	maxUserNameLength   = 255
+)
+
+var (
+	ErrUnknownOAuthProvider   = errors.New("unknown sign in provider")
+	ErrInvalidOAuthState      = errors.New("sign in with the provider is invalid or has expired, start again")
+	ErrOAuthDenied            = errors.New("sign in was cancelled at the provider")
+	ErrOAuthProvider          = errors.New("sign in provider is unavailable")
+	ErrOAuthEmailRequired     = errors.New("the provider did not share an email address")
+	ErrOAuthEmailNotVerified  = errors.New("the provider has not confirmed the email address")
+	ErrOAuthAccountUnverified = errors.New("an account with this email exists but its email is not confirmed, sign in with the password and confirm it first")
+)
+
+// OAuthConfig lists the sign in providers. Their callbacks are served at
+// RedirectBaseURL + /auth/oauth/<name>/callback, which has to be registered
+// with each provider. When FrontendURL is set the callback redirects there
+// with the result in the URL fragment instead of answering with JSON.
+type OAuthConfig struct {
+	RedirectBaseURL string
+	FrontendURL     string
+	Providers       []OAuthProviderConfig
+}
+
+func newOAuthClients(config OAuthConfig) map[string]*OAuthClient {
+	clients := make(map[string]*OAuthClient, len(config.Providers))
+	for _, provider := range config.Providers {
+		clients[provider.Name] = NewOAuthClient(provider)
+	}
+	return clients
+}
+
+func (s *AuthService) oauthClient(provider string) (*OAuthClient, error) {
+	client, ok := s.oauthClients[provider]
+	if !ok {
+		return nil, ErrUnknownOAuthProvider
+	}
+	return client, nil
+}
+
+func (s *AuthService) oauthRedirectURL(provider string) string {
+	return strings.TrimRight(s.oauth.RedirectBaseURL, "/") + "/auth/oauth/" + url.PathEscape(provider) + "/callback"
+}
+
+func (s *AuthService) OAuthFrontendURL() string {
+	return s.oauth.FrontendURL
+}
+
+// StartOAuth returns the provider's authorization URL and the PKCE verifier,
+// which the caller keeps in the browser until the callback. The state is a
+// signed token bound to that verifier, so a callback can't be replayed from
+// another browser.
+func (s *AuthService) StartOAuth(provider string) (string, string, error) {
+	client, err := s.oauthClient(provider)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := newPKCEVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	state, err := s.NewToken(jwt.MapClaims{
+		"exp":      time.Now().Add(oauthStateTTL).Unix(),
+		"typ":      oauthStateTokenType,
+		"provider": provider,
+		"vh":       hashToken(verifier),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	authURL, err := client.AuthCodeURL(s.oauthRedirectURL(provider), state, verifier)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, verifier, nil
+}
+
+// FinishOAuth handles the provider's callback and signs in the linked user,
+// linking or creating one by the email the provider confirmed.
+func (s *AuthService) FinishOAuth(ctx context.Context, provider string, callback url.Values, verifier string, userAgent string, ip string) (SignInResult, error) {
+	client, err := s.oauthClient(provider)
+	if err != nil {
+		return SignInResult{}, err
+	}
+	if callback.Get("error") != "" {
+		return SignInResult{}, ErrOAuthDenied
+	}
+	claims, err := s.parseClaims(callback.Get("state"), oauthStateTokenType)
+	if err != nil || verifier == "" || claims["provider"] != provider || claims["vh"] != hashToken(verifier) {
+		return SignInResult{}, ErrInvalidOAuthState
+	}
+	accessToken, err := client.Exchange(ctx, s.oauthRedirectURL(provider), callback, verifier)
+	if err != nil {
+		logrus.Errorf("error exchanging oauth code: %s", err.Error())
+		return SignInResult{}, ErrOAuthProvider
+	}
+	info, err := client.UserInfo(ctx, accessToken)
+	if err != nil {
+		logrus.Errorf("error getting oauth user info: %s", err.Error())
+		return SignInResult{}, ErrOAuthProvider
+	}
+	userId, err := s.oauthUser(provider, info)
+	if err != nil {
+		return SignInResult{}, err
+	}
+	user, err := s.repo.Auth.GetUser(userId)
+	if err != nil {
+		return SignInResult{}, err
+	}
+	return s.completeSignIn(user, userAgent, ip)
+}
+
+// oauthUser finds the user linked to the external account. Linking to an
+// existing account and creating a new one both require an email confirmed by
+// the provider, otherwise anyone could claim someone else's address there.
+// Accounts that never confirmed their own email aren't linked either: whoever
+// registered them may not own the address, and linking would leave their
+// password working on an account the address owner now signs in to.
+func (s *AuthService) oauthUser(provider string, info OAuthUserInfo) (int, error) {
+	identity, err := s.repo.Identities.GetIdentity(provider, info.Subject)
+	if err == nil {
+		return identity.UserID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if info.Email == "" {
+		return 0, ErrOAuthEmailRequired
+	}
+	if !info.EmailVerified {
+		return 0, ErrOAuthEmailNotVerified
+	}
+	identity = model.Identity{
+		Provider: provider,
+		Subject:  info.Subject,
+		Email:    &info.Email,
+	}
+	user, err := s.repo.Auth.GetUserByEmail(info.Email)
+	if err == nil {
+		if user.EmailVerifiedAt == nil {
+			return 0, ErrOAuthAccountUnverified
+		}
+		identity.UserID = user.ID
+		if err := s.repo.Identities.LinkIdentity(identity); err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	name := strings.TrimSpace(info.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(info.Email, "@")
+	}
+	return s.repo.Identities.CreateUserWithIdentity(model.User{
+		Name:  truncate(name, maxUserNameLength),
+		Email: info.Email,
+	}, identity, true)
+}
+
+func (s *AuthService) GetUserIdentities(userId int) ([]model.Identity, error) {
+	return s.repo.Identities.GetUserIdentities(userId)
+}
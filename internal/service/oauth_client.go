@@ -0,0 +1,220 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	userInfoAuthBearer = "bearer"
+	userInfoAuthOAuth  = "oauth"
+	userInfoAuthForm   = "form"
+)
+
+// OAuthProviderConfig describes an OAuth 2.0 / OIDC provider. Standard OIDC
+// providers need only the endpoints and client credentials; for the others
+// the user info fields are dot paths into the JSON response (VK ID puts them
+// under "user"), UserInfoAuth says how the access token is sent and
+// TokenParams lists callback parameters that the token request must repeat.
+type OAuthProviderConfig struct {
+	Name               string
+	AuthURL            string
+	TokenURL           string
+	UserInfoURL        string
+	ClientID           string
+	ClientSecret       string
+	Scopes             []string
+	UserInfoAuth       string
+	TokenParams        []string
+	SubjectField       string
+	EmailField         string
+	EmailVerifiedField string
+	NameField          string
+	TrustEmail         bool
+}
+
+// OAuthUserInfo is what the flow needs to know about the external account.
+type OAuthUserInfo struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type OAuthClient struct {
+	config OAuthProviderConfig
+	client *http.Client
+}
+
+func NewOAuthClient(config OAuthProviderConfig) *OAuthClient {
+	if config.UserInfoAuth == "" {
+		config.UserInfoAuth = userInfoAuthBearer
+	}
+	if config.SubjectField == "" {
+		config.SubjectField = "sub"
+	}
+	if config.EmailField == "" {
+		config.EmailField = "email"
+	}
+	if config.EmailVerifiedField == "" {
+		config.EmailVerifiedField = "email_verified"
+	}
+	if config.NameField == "" {
+		config.NameField = "name"
+	}
+	return &OAuthClient{
+		config: config,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func newPKCEVerifier() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func pkceChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func (c *OAuthClient) AuthCodeURL(redirectURL string, state string, verifier string) (string, error) {
+	authURL, err := url.Parse(c.config.AuthURL)
+	if err != nil {
+		return "", err
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.config.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("state", state)
+	query.Set("code_challenge", pkceChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	if len(c.config.Scopes) > 0 {
+		query.Set("scope", strings.Join(c.config.Scopes, " "))
+	}
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange trades the authorization code for an access token.
+func (c *OAuthClient) Exchange(ctx context.Context, redirectURL string, callback url.Values, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {callback.Get("code")},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {c.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	if c.config.ClientSecret != "" {
+		form.Set("client_secret", c.config.ClientSecret)
+	}
+	for _, param := range c.config.TokenParams {
+		form.Set(param, callback.Get(param))
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := c.postForm(ctx, c.config.TokenURL, form, &result); err != nil {
+		return "", err
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("oauth %s: token response has no access token", c.config.Name)
+	}
+	return result.AccessToken, nil
+}
+
+func (c *OAuthClient) UserInfo(ctx context.Context, accessToken string) (OAuthUserInfo, error) {
+	var result map[string]interface{}
+	switch c.config.UserInfoAuth {
+	case userInfoAuthForm:
+		form := url.Values{"access_token": {accessToken}, "client_id": {c.config.ClientID}}
+		if err := c.postForm(ctx, c.config.UserInfoURL, form, &result); err != nil {
+			return OAuthUserInfo{}, err
+		}
+	default:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.UserInfoURL, nil)
+		if err != nil {
+			return OAuthUserInfo{}, err
+		}
+		scheme := "Bearer "
+		if c.config.UserInfoAuth == userInfoAuthOAuth {
+			scheme = "OAuth "
+		}
+		req.Header.Set("Authorization", scheme+accessToken)
+		if err := c.do(req, &result); err != nil {
+			return OAuthUserInfo{}, err
+		}
+	}
+	info := OAuthUserInfo{
+		Subject: jsonField(result, c.config.SubjectField),
+		Email:   jsonField(result, c.config.EmailField),
+		Name:    jsonField(result, c.config.NameField),
+	}
+	if info.Subject == "" {
+		return OAuthUserInfo{}, fmt.Errorf("oauth %s: user info has no %s", c.config.Name, c.config.SubjectField)
+	}
+	info.EmailVerified = info.Email != "" && (c.config.TrustEmail || jsonField(result, c.config.EmailVerifiedField) == "true")
+	return info, nil
+}
+
+func (c *OAuthClient) postForm(ctx context.Context, endpoint string, form url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, result)
+}
+
+func (c *OAuthClient) do(req *http.Request, result interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("oauth %s: %s %s: %d %s", c.config.Name, req.Method, req.URL.Path, resp.StatusCode, string(data))
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	return decoder.Decode(result)
+}
+
+// jsonField returns the value at a dot path as a string, so numeric ids and
+// boolean flags can be read the same way as strings.
+func jsonField(data map[string]interface{}, path string) string {
+	var value interface{} = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return fmt.Sprint(value)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const (
+	fakeOIDCCode        = "test-code"
+	fakeOIDCAccessToken = "test-access-token"
+	fakeOIDCRedirectURL = "http://localhost:8000/auth/oauth/fake/callback"
+)
+
+// fakeOIDCServer is a minimal OIDC provider: the token endpoint checks the
+// code, redirect URL and PKCE verifier against the challenge the client sent
+// to the authorization endpoint, and the user info endpoint answers with
+// userInfo for the issued access token.
+type fakeOIDCServer struct {
+	*httptest.Server
+	challenge   string
+	tokenForm   url.Values
+	userInfoReq *http.Request
+	userInfo    map[string]interface{}
+}
+
+func newFakeOIDCServer(t *testing.T, userInfo map[string]interface{}) *fakeOIDCServer {
+	t.Helper()
+	server := &fakeOIDCServer{userInfo: userInfo}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.tokenForm = r.PostForm
+		if r.PostForm.Get("code") != fakeOIDCCode ||
+			r.PostForm.Get("redirect_uri") != fakeOIDCRedirectURL ||
+			pkceChallenge(r.PostForm.Get("code_verifier")) != server.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": fakeOIDCAccessToken, "token_type": "Bearer"})
+	})
+	userInfoHandler := func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		server.userInfoReq = r
+		token := r.PostForm.Get("access_token")
+		if token == "" {
+			_, token, _ = strings.Cut(r.Header.Get("Authorization"), " ")
+		}
+		if token != fakeOIDCAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(server.userInfo)
+	}
+	mux.HandleFunc("/userinfo", userInfoHandler)
+	server.Server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *fakeOIDCServer) config() OAuthProviderConfig {
+	return OAuthProviderConfig{
+		Name:        "fake",
+		AuthURL:     s.URL + "/authorize",
+		TokenURL:    s.URL + "/token",
+		UserInfoURL: s.URL + "/userinfo",
+		ClientID:    "dresscode",
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+}
+
+// authorize plays the browser's part: it opens the authorization URL the
+// client built and returns the callback the provider would redirect to.
+func (s *fakeOIDCServer) authorize(t *testing.T, client *OAuthClient, verifier string, extra url.Values) url.Values {
+	t.Helper()
+	authURL, err := client.AuthCodeURL(fakeOIDCRedirectURL, "test-state", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %s", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing auth url: %s", err)
+	}
+	query := parsed.Query()
+	if parsed.Path != "/authorize" || query.Get("client_id") != "dresscode" || query.Get("redirect_uri") != fakeOIDCRedirectURL ||
+		query.Get("response_type") != "code" || query.Get("state") != "test-state" || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+	s.challenge = query.Get("code_challenge")
+	callback := url.Values{"code": {fakeOIDCCode}, "state": {query.Get("state")}}
+	for key, values := range extra {
+		callback[key] = values
+	}
+	return callback
+}
+
+func TestOAuthClientOIDCFlow(t *testing.T) {
+	server := newFakeOIDCServer(t, map[string]interface{}{
+		"sub":            "user-1",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Test User",
+	})
+	client := NewOAuthClient(server.config())
+	verifier, err := newPKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := server.authorize(t, client, verifier, nil)
+
+	accessToken, err := client.Exchange(context.Background(), fakeOIDCRedirectURL, callback, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %s", err)
+	}
+	if server.tokenForm.Get("client_secret") != "" {
+		t.Errorf("client secret sent for a public client")
+	}
+	info, err := client.UserInfo(context.Background(), accessToken)
+	if err != nil {
+		t.Fatalf("UserInfo: %s", err)
+	}
+	want := OAuthUserInfo{Subject: "user-1", Email: "user@example.com", EmailVerified: true, Name: "Test User"}
+	if info != want {
+		t.Errorf("UserInfo = %+v, want %+v", info, want)
+	}
+	if auth := server.userInfoReq.Header.Get("Authorization"); auth != "Bearer "+fakeOIDCAccessToken {
+		t.Errorf("user info Authorization = %q", auth)
+	}
+}
+
+func TestOAuthClientRejectsWrongVerifier(t *testing.T) {
+	server := newFakeOIDCServer(t, map[string]interface{}{"sub": "user-1"})
+	client := NewOAuthClient(server.config())
+	callback := server.authorize(t, client, "verifier-from-the-browser", nil)
+
+	if _, err := client.Exchange(context.Background(), fakeOIDCRedirectURL, callback, "another-verifier"); err == nil {
+		t.Fatal("Exchange succeeded with a wrong PKCE verifier")
+	}
+}
+
+func TestOAuthClientUnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		userInfo map[string]interface{}
+	}{
+		{"not verified", map[string]interface{}{"sub": "user-1", "email": "user@example.com", "email_verified": false}},
+		{"no claim", map[string]interface{}{"sub": "user-1", "email": "user@example.com"}},
+		{"string claim", map[string]interface{}{"sub": "user-1", "email": "user@example.com", "email_verified": "false"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeOIDCServer(t, test.userInfo)
+			info, err := NewOAuthClient(server.config()).UserInfo(context.Background(), fakeOIDCAccessToken)
+			if err != nil {
+				t.Fatalf("UserInfo: %s", err)
+			}
+			if info.EmailVerified {
+				t.Errorf("EmailVerified = true for %v", test.userInfo)
+			}
+		})
+	}
+}
+
+func TestOAuthClientCustomFields(t *testing.T) {
+	server := newFakeOIDCServer(t, map[string]interface{}{
+		"user": map[string]interface{}{
+			"user_id":    json.Number("123456"),
+			"email":      "user@example.com",
+			"first_name": "Test",
+		},
+	})
+	config := server.config()
+	config.UserInfoAuth = userInfoAuthForm
+	config.TokenParams = []string{"device_id"}
+	config.SubjectField = "user.user_id"
+	config.EmailField = "user.email"
+	config.NameField = "user.first_name"
+	config.TrustEmail = true
+	client := NewOAuthClient(config)
+	verifier, err := newPKCEVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := server.authorize(t, client, verifier, url.Values{"device_id": {"device-1"}})
+
+	accessToken, err := client.Exchange(context.Background(), fakeOIDCRedirectURL, callback, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %s", err)
+	}
+	if server.tokenForm.Get("device_id") != "device-1" {
+		t.Errorf("token request device_id = %q", server.tokenForm.Get("device_id"))
+	}
+	info, err := client.UserInfo(context.Background(), accessToken)
+	if err != nil {
+		t.Fatalf("UserInfo: %s", err)
+	}
+	want := OAuthUserInfo{Subject: "123456", Email: "user@example.com", EmailVerified: true, Name: "Test"}
+	if info != want {
+		t.Errorf("UserInfo = %+v, want %+v", info, want)
+	}
+	if server.userInfoReq.Method != http.MethodPost {
+		t.Errorf("user info method = %s, want POST", server.userInfoReq.Method)
+	}
+}
+
+func TestOAuthClientMissingSubject(t *testing.T) {
+	server := newFakeOIDCServer(t, map[string]interface{}{"email": "user@example.com", "email_verified": true})
+	if _, err := NewOAuthClient(server.config()).UserInfo(context.Background(), fakeOIDCAccessToken); err == nil {
+		t.Fatal("UserInfo succeeded without a subject")
+	}
+}
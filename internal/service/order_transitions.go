@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+)
+
+var (
+	ErrIllegalOrderTransition   = errors.New("illegal order status transition")
+	ErrOrderTransitionForbidden = errors.New("not allowed to perform this order status transition")
+	ErrOrderNotCancellable      = errors.New("order can no longer be cancelled")
+	ErrOrderCancelForbidden     = errors.New("only admins can cancel an order after it has been handed over to the shop")
+	ErrOrderNotReturnable       = errors.New("products can only be returned from an issued or delivered order")
+)
+
+type OrderTransitionError struct {
+	OrderType string
+	From      string
+	To        string
+	Actor     string
+	Err       error
+}
+
+func (e *OrderTransitionError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s for %s order by %s", e.Err.Error(), e.From, e.To, e.OrderType, e.Actor)
+}
+
+func (e *OrderTransitionError) Unwrap() error {
+	return e.Err
+}
+
+// orderTransition may be performed by callers with the permission and, when
+// system is set, by the payment and delivery workers.
+type orderTransition struct {
+	from       string
+	to         string
+	system     bool
+	permission Permission
+}
+
+var orderTransitions = map[string][]orderTransition{
+	PickupOrderType: {
+		{from: PendingOrderStatus, to: CreatedOrderStatus, system: true, permission: OrdersOverride},
+		{from: CreatedOrderStatus, to: DeliveredToShopOrderStatus, permission: OrdersFulfil},
+		{from: DeliveredToShopOrderStatus, to: IssuedOrderStatus, permission: OrdersFulfil},
+	},
+	DeliveryOrderType: {
+		{from: PendingOrderStatus, to: CreatedOrderStatus, system: true, permission: OrdersOverride},
+		{from: CreatedOrderStatus, to: SentToCustomerOrderStatus, system: true, permission: OrdersFulfil},
+		{from: SentToCustomerOrderStatus, to: DeliveredToCustomerOrderStatus, system: true, permission: OrdersFulfil},
+	},
+}
+
+func checkOrderTransition(orderType string, from string, to string, actor OrderActor) error {
+	for _, transition := range orderTransitions[orderType] {
+		if transition.from != from || transition.to != to {
+			continue
+		}
+		if (actor.system && transition.system) || actor.Can(transition.permission) {
+			return nil
+		}
+		return &OrderTransitionError{OrderType: orderType, From: from, To: to, Actor: actor.String(), Err: ErrOrderTransitionForbidden}
+	}
+	return &OrderTransitionError{OrderType: orderType, From: from, To: to, Actor: actor.String(), Err: ErrIllegalOrderTransition}
+}
+
+var customerCancellableStatuses = []string{PendingOrderStatus, CreatedOrderStatus}
+
+var returnableStatuses = []string{IssuedOrderStatus, DeliveredToCustomerOrderStatus}
+
+func checkOrderCancellation(order model.Order, actor OrderActor) error {
+	if order.Status == CancelledOrderStatus || order.Status == ReturnedOrderStatus {
+		return ErrOrderNotCancellable
+	}
+	if actor.Can(OrdersOverride) {
+		return nil
+	}
+	if order.UserID == actor.UserID && containsString(customerCancellableStatuses, order.Status) {
+		return nil
+	}
+	return ErrOrderCancelForbidden
+}
+
+func checkOrderReturn(order model.Order, actor OrderActor) error {
+	if !actor.Can(OrdersRefund) {
+		return ErrUserNotBuyer
+	}
+	if !containsString(returnableStatuses, order.Status) {
+		return ErrOrderNotReturnable
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,342 @@
 package service
 
-import "github.com/lavatee/dresscode_backend/internal/repository"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DeliveryOrderType = "delivery"
+	PickupOrderType   = "pickup"
+
+	PendingOrderStatus             = "pending"
+	CreatedOrderStatus             = "created"
+	DeliveredToShopOrderStatus     = "delivered_to_shop"
+	IssuedOrderStatus              = "issued"
+	SentToCustomerOrderStatus      = "sent_to_customer"
+	DeliveredToCustomerOrderStatus = "delivered_to_customer"
+	CancelledOrderStatus           = "cancelled"
+	ReturnedOrderStatus            = "returned"
+
+	adminRole    = "admin"
+	buyerRole    = "buyer"
+	customerRole = "customer"
+	systemRole   = "system"
+)
+
+var (
+	ErrEmptyCart              = errors.New("cart is empty")
+	ErrCartProductUnavailable = errors.New("cart contains products that are no longer available")
+	ErrInvalidOrderType       = errors.New("invalid order type")
+	ErrShopPointRequired      = errors.New("shop point is required for pickup orders")
+	ErrDeliveryAddressMissing = errors.New("delivery address and index are required for delivery orders")
+	ErrOrderNotFound          = errors.New("order not found")
+	ErrOrderAccessDenied      = errors.New("order belongs to another user")
+	ErrInvalidReturnedProduct = errors.New("returned amount must be positive")
+)
 
 type OrdersService struct {
-	repo *repository.Repository
+	repo           *repository.Repository
+	payments       PaymentProvider
+	delivery       DeliveryProvider
+	paymentTTL     time.Duration
+	reservationTTL time.Duration
 }
 
-func NewOrdersService(repo *repository.Repository) *OrdersService {
+func NewOrdersService(repo *repository.Repository, payments PaymentProvider, delivery DeliveryProvider, paymentTTL time.Duration, reservationTTL time.Duration) *OrdersService {
 	return &OrdersService{
-		repo: repo,
+		repo:           repo,
+		payments:       payments,
+		delivery:       delivery,
+		paymentTTL:     paymentTTL,
+		reservationTTL: reservationTTL,
+	}
+}
+
+// checkEmailVerified rejects checkout for accounts that haven't confirmed
+// their email, since order and payment notifications go there.
+func (s *OrdersService) checkEmailVerified(userId int) error {
+	user, err := s.repo.Auth.GetUser(userId)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+func (s *OrdersService) StartCheckout(userId int) ([]model.StockReservation, error) {
+	if err := s.checkEmailVerified(userId); err != nil {
+		return nil, err
+	}
+	productsInCart, err := s.repo.Products.GetProductsInCart(model.CartOwner{UserID: userId})
+	if err != nil {
+		return nil, err
+	}
+	if len(productsInCart) == 0 {
+		return nil, ErrEmptyCart
+	}
+	sizes := make([]repository.SizeInfo, 0, len(productsInCart))
+	for _, productInCart := range productsInCart {
+		if !productInCart.Exists {
+			return nil, ErrCartProductUnavailable
+		}
+		sizes = append(sizes, repository.SizeInfo{ProductID: productInCart.ProductID, SizeName: productInCart.Size, Amount: productInCart.Amount})
+	}
+	return s.repo.Reservations.ReserveCart(userId, sizes, time.Now().Add(s.reservationTTL))
+}
+
+func (s *OrdersService) ReleaseExpiredReservations(ctx context.Context) error {
+	released, err := s.repo.Reservations.ReleaseExpiredReservations()
+	if err != nil {
+		return err
+	}
+	if released > 0 {
+		logrus.Infof("Released %d expired stock reservations", released)
+	}
+	return nil
+}
+
+func (s *OrdersService) CreateOrder(ctx context.Context, userId int, order model.Order) (model.Order, error) {
+	if err := s.checkEmailVerified(userId); err != nil {
+		return model.Order{}, err
+	}
+	switch order.Type {
+	case PickupOrderType:
+		if order.ShopPointID == nil {
+			return model.Order{}, ErrShopPointRequired
+		}
+		shopPoint, err := s.repo.ShopPoints.GetShopPoint(*order.ShopPointID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.Order{}, ErrShopPointNotFound
+			}
+			return model.Order{}, err
+		}
+		if !shopPoint.IsActive {
+			return model.Order{}, ErrShopPointInactive
+		}
+		order.ShopPoint = shopPoint.Address
+		order.DeliveryAddress = ""
+		order.DeliveryIndex = 0
+		order.AddressID = nil
+		order.RecipientName = nil
+		order.RecipientPhone = nil
+	case DeliveryOrderType:
+		// A saved address is copied into the order, so editing or deleting it
+		// later doesn't change the order.
+		if order.AddressID != nil {
+			address, err := getAddress(s.repo, userId, *order.AddressID)
+			if err != nil {
+				return model.Order{}, err
+			}
+			order.DeliveryAddress = formatAddress(address)
+			order.DeliveryIndex = address.PostalIndex
+			order.RecipientName = &address.RecipientName
+			order.RecipientPhone = &address.Phone
+		} else {
+			order.RecipientName = nil
+			order.RecipientPhone = nil
+		}
+		if order.DeliveryAddress == "" || order.DeliveryIndex == 0 {
+			return model.Order{}, ErrDeliveryAddressMissing
+		}
+		if !validPostalIndex(order.DeliveryIndex) {
+			return model.Order{}, ErrInvalidPostalIndex
+		}
+		order.ShopPoint = ""
+		order.ShopPointID = nil
+	default:
+		return model.Order{}, ErrInvalidOrderType
+	}
+	productsInCart, err := s.repo.Products.GetProductsInCart(model.CartOwner{UserID: userId})
+	if err != nil {
+		return model.Order{}, err
+	}
+	if len(productsInCart) == 0 {
+		return model.Order{}, ErrEmptyCart
+	}
+	order.OrderedProducts = make([]model.OrderedProduct, 0, len(productsInCart))
+	order.OrderPrice = 0
+	for _, productInCart := range productsInCart {
+		if !productInCart.Exists {
+			return model.Order{}, ErrCartProductUnavailable
+		}
+		order.OrderedProducts = append(order.OrderedProducts, model.OrderedProduct{
+			ProductID:   productInCart.ProductID,
+			Size:        productInCart.Size,
+			Amount:      productInCart.Amount,
+			Price:       productInCart.Price,
+			ProductName: productInCart.ProductName,
+		})
+		order.OrderPrice += productInCart.Price * productInCart.Amount
+	}
+	order.DiscountAmount = 0
+	order.PromoCodeID = nil
+	if order.PromoCode != nil && *order.PromoCode != "" {
+		promoCode, discounts, err := resolvePromoCode(s.repo, userId, *order.PromoCode, productsInCart, time.Now())
+		if err != nil {
+			return model.Order{}, err
+		}
+		for i := range order.OrderedProducts {
+			order.OrderedProducts[i].DiscountAmount = discounts[i]
+		}
+		order.PromoCodeID = &promoCode.ID
+		order.PromoCode = &promoCode.Code
+		order.DiscountAmount = sumDiscounts(discounts)
+		order.OrderPrice -= order.DiscountAmount
+	} else {
+		order.PromoCode = nil
+	}
+	order.UserID = userId
+	order.Status = PendingOrderStatus
+	order.DeliveryPrice = 0
+	if order.Type == DeliveryOrderType {
+		quote, err := s.delivery.Quote(ctx, order.DeliveryIndex, cartWeight(productsInCart))
+		if err != nil {
+			return model.Order{}, err
+		}
+		order.DeliveryPrice = quote.Price
+	}
+	return s.repo.Orders.CreateOrder(order, time.Now().Add(s.paymentTTL))
+}
+
+func (s *OrdersService) GetUserOrders(userId int, status string, orderType string) ([]model.Order, error) {
+	return s.repo.Orders.GetUserOrders(userId, status, orderType)
+}
+
+func (s *OrdersService) GetOrder(actor OrderActor, orderId int) (model.Order, error) {
+	order, err := s.repo.Orders.GetOrder(orderId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Order{}, ErrOrderNotFound
+		}
+		return model.Order{}, err
+	}
+	if order.UserID != actor.UserID && !actor.Can(OrdersReadAll) {
+		return model.Order{}, ErrOrderAccessDenied
+	}
+	return order, nil
+}
+
+func (s *OrdersService) GetDeliveryOrders(filter model.OrdersFilter) ([]model.Order, error) {
+	return s.repo.Orders.GetDeliveryOrders(filter)
+}
+
+func (s *OrdersService) GetPickupOrders(filter model.OrdersFilter) ([]model.Order, error) {
+	return s.repo.Orders.GetPickupOrders(filter)
+}
+
+func (s *OrdersService) SetOrderStatus(actor OrderActor, orderId int, status string) error {
+	order, err := s.GetOrder(actor, orderId)
+	if err != nil {
+		return err
+	}
+	if err := checkOrderTransition(order.Type, order.Status, status, actor); err != nil {
+		return err
+	}
+	return s.repo.Orders.SetOrderStatus(orderId, order.Status, status, actor.UserID)
+}
+
+func (s *OrdersService) GetOrderStatusHistory(actor OrderActor, orderId int) ([]model.OrderStatusChange, error) {
+	if _, err := s.GetOrder(actor, orderId); err != nil {
+		return nil, err
+	}
+	return s.repo.Orders.GetOrderStatusHistory(orderId)
+}
+
+func (s *OrdersService) CancelOrder(ctx context.Context, actor OrderActor, orderId int) error {
+	order, err := s.GetOrder(actor, orderId)
+	if err != nil {
+		return err
+	}
+	if err := checkOrderCancellation(order, actor); err != nil {
+		return err
+	}
+	refund := model.Refund{Reason: CancelRefundReason}
+	if order.PaymentID != "" && order.Status != PendingOrderStatus {
+		refund.Amount = order.DeliveryPrice
+		for _, orderedProduct := range order.OrderedProducts {
+			refund.Amount += orderedProductRefund(orderedProduct, orderedProduct.Amount-orderedProduct.ReturnedAmount)
+		}
+	}
+	if err := s.repo.Orders.CancelOrder(orderId, order.Status, actor.UserID, refund); err != nil {
+		return err
+	}
+	s.sendRefunds(ctx, orderId)
+	return nil
+}
+
+// sendRefunds sends the refunds recorded by an order change. The change is
+// already committed, so a failed refund is left to the refunds worker.
+func (s *OrdersService) sendRefunds(ctx context.Context, orderId int) {
+	if err := sendRefunds(ctx, s.repo, s.payments, orderId); err != nil {
+		logrus.Errorf("error refunding order %d, will retry: %s", orderId, err.Error())
+	}
+}
+
+func (s *OrdersService) ReturnOrderedProducts(ctx context.Context, actor OrderActor, orderId int, returnedProducts []model.ReturnedProduct) (bool, error) {
+	if len(returnedProducts) == 0 {
+		return false, ErrInvalidReturnedProduct
+	}
+	for _, returnedProduct := range returnedProducts {
+		if returnedProduct.Amount <= 0 {
+			return false, ErrInvalidReturnedProduct
+		}
+	}
+	order, err := s.GetOrder(actor, orderId)
+	if err != nil {
+		return false, err
+	}
+	if err := checkOrderReturn(order, actor); err != nil {
+		return false, err
+	}
+	refund := model.Refund{Reason: ReturnRefundReason}
+	if order.PaymentID != "" {
+		refund.Amount, err = returnRefundAmount(order, returnedProducts)
+		if err != nil {
+			return false, err
+		}
+	}
+	fullyReturned, err := s.repo.Orders.ReturnOrderedProducts(orderId, order.Status, returnedProducts, actor.UserID, refund)
+	if err != nil {
+		return false, err
+	}
+	s.sendRefunds(ctx, orderId)
+	return fullyReturned, nil
+}
+
+func returnRefundAmount(order model.Order, returnedProducts []model.ReturnedProduct) (int, error) {
+	orderedProducts := make(map[int]model.OrderedProduct, len(order.OrderedProducts))
+	for _, orderedProduct := range order.OrderedProducts {
+		orderedProducts[orderedProduct.ID] = orderedProduct
+	}
+	refund := 0
+	for _, returnedProduct := range returnedProducts {
+		orderedProduct, ok := orderedProducts[returnedProduct.OrderedProductID]
+		if !ok || returnedProduct.Amount > orderedProduct.Amount-orderedProduct.ReturnedAmount {
+			return 0, repository.ErrInvalidReturnAmount
+		}
+		refund += orderedProductRefund(orderedProduct, returnedProduct.Amount)
+	}
+	return refund, nil
+}
+
+// orderedProductRefund returns what was paid for the next amount units of the
+// ordered product. The line discount is attributed to units cumulatively so
+// refunds of all units add up to exactly what the customer paid.
+func orderedProductRefund(orderedProduct model.OrderedProduct, amount int) int {
+	if orderedProduct.Amount == 0 {
+		return 0
 	}
+	returned := orderedProduct.ReturnedAmount
+	discount := orderedProduct.DiscountAmount*(returned+amount)/orderedProduct.Amount - orderedProduct.DiscountAmount*returned/orderedProduct.Amount
+	return orderedProduct.Price*amount - discount
 }
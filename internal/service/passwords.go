@@ -0,0 +1,54 @@
+package service
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordHashCost = 12
+	legacySalt       = "jd83420s32vv"
+)
+
+// dummyPasswordHash is compared against when the user does not exist, so
+// unknown emails take as long to reject as wrong passwords.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), passwordHashCost)
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// legacyHashPassword reproduces the old SHA-1 scheme, which did not hash the
+// salt: the raw salt bytes were prepended to the digest and the result was
+// hex encoded as a whole.
+func legacyHashPassword(password string) string {
+	sha := sha1.New()
+	sha.Write([]byte(password))
+	return fmt.Sprintf("%x", sha.Sum([]byte(legacySalt)))
+}
+
+func isLegacyPasswordHash(hash string) bool {
+	return !strings.HasPrefix(hash, "$2")
+}
+
+// checkPassword reports whether the password matches the hash and whether the
+// hash should be replaced with a fresh one.
+func checkPassword(hash string, password string) (bool, bool) {
+	if isLegacyPasswordHash(hash) {
+		ok := subtle.ConstantTimeCompare([]byte(hash), []byte(legacyHashPassword(password))) == 1
+		return ok, ok
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err == nil && cost < passwordHashCost
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+type FakePaymentProvider struct {
+	mu            sync.Mutex
+	webhookSecret string
+	autoSucceed   bool
+	counter       int
+	payments      map[string]*Payment
+	refunds       map[string]bool
+}
+
+func NewFakePaymentProvider(webhookSecret string, autoSucceed bool) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		webhookSecret: webhookSecret,
+		autoSucceed:   autoSucceed,
+		payments:      make(map[string]*Payment),
+		refunds:       make(map[string]bool),
+	}
+}
+
+func (p *FakePaymentProvider) CreatePayment(ctx context.Context, request PaymentRequest) (Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counter++
+	payment := &Payment{
+		ID:              fmt.Sprintf("fake-%d-%d", request.OrderID, p.counter),
+		Status:          PaymentPending,
+		Amount:          request.Amount,
+		ConfirmationURL: request.ReturnURL,
+	}
+	if p.autoSucceed {
+		payment.Status = PaymentSucceeded
+	}
+	p.payments[payment.ID] = payment
+	return *payment, nil
+}
+
+func (p *FakePaymentProvider) GetPayment(ctx context.Context, paymentId string) (Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[paymentId]
+	if !ok {
+		return Payment{}, ErrPaymentNotFound
+	}
+	return *payment, nil
+}
+
+func (p *FakePaymentProvider) Refund(ctx context.Context, paymentId string, amount int, idempotenceKey string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refunds[idempotenceKey] {
+		return nil
+	}
+	payment, ok := p.payments[paymentId]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if amount > payment.Amount {
+		return fmt.Errorf("refund amount %d exceeds payment amount %d", amount, payment.Amount)
+	}
+	payment.Amount -= amount
+	if payment.Amount == 0 {
+		payment.Status = PaymentCanceled
+	}
+	p.refunds[idempotenceKey] = true
+	return nil
+}
+
+func (p *FakePaymentProvider) SetPaymentStatus(paymentId string, status string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	payment, ok := p.payments[paymentId]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	payment.Status = status
+	return nil
+}
+
+func (p *FakePaymentProvider) ParseWebhook(request WebhookRequest) (PaymentEvent, error) {
+	if err := verifyWebhookSignature(p.webhookSecret, request.Body, request.Signature); err != nil {
+		return PaymentEvent{}, err
+	}
+	var webhook yooKassaWebhook
+	if err := json.Unmarshal(request.Body, &webhook); err != nil {
+		return PaymentEvent{}, err
+	}
+	if err := p.SetPaymentStatus(webhook.Object.ID, webhook.Object.Status); err != nil {
+		return PaymentEvent{}, err
+	}
+	return PaymentEvent{PaymentID: webhook.Object.ID, Status: webhook.Object.Status}, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type YooKassaConfig struct {
+	URL             string
+	ShopID          string
+	SecretKey       string
+	WebhookNetworks []string
+}
+
+type YooKassaProvider struct {
+	config          YooKassaConfig
+	client          *http.Client
+	webhookNetworks []*net.IPNet
+}
+
+// NewYooKassaProvider parses the networks YooKassa sends notifications from.
+// YooKassa does not sign notifications, so the source address is checked and
+// the payment status is always re-read from the API before it is trusted.
+func NewYooKassaProvider(config YooKassaConfig) (*YooKassaProvider, error) {
+	webhookNetworks := make([]*net.IPNet, 0, len(config.WebhookNetworks))
+	for _, network := range config.WebhookNetworks {
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, err
+		}
+		webhookNetworks = append(webhookNetworks, ipNet)
+	}
+	return &YooKassaProvider{
+		config:          config,
+		client:          &http.Client{Timeout: 15 * time.Second},
+		webhookNetworks: webhookNetworks,
+	}, nil
+}
+
+type yooKassaAmount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+type yooKassaPayment struct {
+	ID           string         `json:"id"`
+	Status       string         `json:"status"`
+	Amount       yooKassaAmount `json:"amount"`
+	Confirmation struct {
+		ConfirmationURL string `json:"confirmation_url"`
+	} `json:"confirmation"`
+}
+
+type yooKassaWebhook struct {
+	Event  string          `json:"event"`
+	Object yooKassaPayment `json:"object"`
+}
+
+func formatRubles(amount int) yooKassaAmount {
+	return yooKassaAmount{Value: fmt.Sprintf("%d.00", amount), Currency: "RUB"}
+}
+
+func parseRubles(amount yooKassaAmount) int {
+	value, err := strconv.ParseFloat(amount.Value, 64)
+	if err != nil {
+		return 0
+	}
+	return int(value)
+}
+
+func (p *YooKassaProvider) CreatePayment(ctx context.Context, request PaymentRequest) (Payment, error) {
+	body := map[string]interface{}{
+		"amount":       formatRubles(request.Amount),
+		"capture":      true,
+		"description":  request.Description,
+		"confirmation": map[string]string{"type": "redirect", "return_url": request.ReturnURL},
+		"metadata":     map[string]string{"order_id": strconv.Itoa(request.OrderID)},
+	}
+	var payment yooKassaPayment
+	if err := p.do(ctx, http.MethodPost, "/payments", uuid.NewString(), body, &payment); err != nil {
+		return Payment{}, err
+	}
+	return Payment{
+		ID:              payment.ID,
+		Status:          payment.Status,
+		Amount:          parseRubles(payment.Amount),
+		ConfirmationURL: payment.Confirmation.ConfirmationURL,
+	}, nil
+}
+
+func (p *YooKassaProvider) GetPayment(ctx context.Context, paymentId string) (Payment, error) {
+	var payment yooKassaPayment
+	if err := p.do(ctx, http.MethodGet, "/payments/"+paymentId, "", nil, &payment); err != nil {
+		return Payment{}, err
+	}
+	return Payment{
+		ID:              payment.ID,
+		Status:          payment.Status,
+		Amount:          parseRubles(payment.Amount),
+		ConfirmationURL: payment.Confirmation.ConfirmationURL,
+	}, nil
+}
+
+func (p *YooKassaProvider) Refund(ctx context.Context, paymentId string, amount int, idempotenceKey string) error {
+	body := map[string]interface{}{
+		"payment_id": paymentId,
+		"amount":     formatRubles(amount),
+	}
+	return p.do(ctx, http.MethodPost, "/refunds", idempotenceKey, body, nil)
+}
+
+func (p *YooKassaProvider) trustedWebhookSource(remoteIP string) bool {
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p.webhookNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *YooKassaProvider) ParseWebhook(request WebhookRequest) (PaymentEvent, error) {
+	if !p.trustedWebhookSource(request.RemoteIP) {
+		return PaymentEvent{}, ErrUntrustedWebhookSource
+	}
+	var webhook yooKassaWebhook
+	if err := json.Unmarshal(request.Body, &webhook); err != nil {
+		return PaymentEvent{}, err
+	}
+	return PaymentEvent{PaymentID: webhook.Object.ID, Status: webhook.Object.Status}, nil
+}
+
+func (p *YooKassaProvider) do(ctx context.Context, method string, path string, idempotenceKey string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.config.URL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.config.ShopID, p.config.SecretKey)
+	req.Header.Set("Content-Type", "application/json")
+	if idempotenceKey != "" {
+		req.Header.Set("Idempotence-Key", idempotenceKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("yookassa: %s %s: %d %s", method, path, resp.StatusCode, string(data))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestYooKassaWebhookNetworks(t *testing.T) {
+	provider, err := NewYooKassaProvider(YooKassaConfig{
+		WebhookNetworks: []string{"185.71.76.0/27", "77.75.156.11/32", "2a02:5180::/32"},
+	})
+	if err != nil {
+		t.Fatalf("NewYooKassaProvider: %s", err)
+	}
+	body := []byte(`{"event":"payment.succeeded","object":{"id":"payment-1","status":"succeeded"}}`)
+	tests := []struct {
+		remoteIP string
+		trusted  bool
+	}{
+		{"185.71.76.1", true},
+		{"185.71.76.31", true},
+		{"185.71.76.32", false},
+		{"77.75.156.11", true},
+		{"77.75.156.12", false},
+		{"2a02:5180::1", true},
+		{"2a02:5181::1", false},
+		{"127.0.0.1", false},
+		{"", false},
+		{"not an ip", false},
+	}
+	for _, test := range tests {
+		event, err := provider.ParseWebhook(WebhookRequest{Body: body, RemoteIP: test.remoteIP})
+		if !test.trusted {
+			if !errors.Is(err, ErrUntrustedWebhookSource) {
+				t.Errorf("webhook from %q = %v, want %v", test.remoteIP, err, ErrUntrustedWebhookSource)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("webhook from %q: %s", test.remoteIP, err)
+			continue
+		}
+		if event.PaymentID != "payment-1" || event.Status != PaymentSucceeded {
+			t.Errorf("webhook from %q = %+v", test.remoteIP, event)
+		}
+	}
+}
+
+func TestYooKassaRejectsInvalidWebhookNetwork(t *testing.T) {
+	if _, err := NewYooKassaProvider(YooKassaConfig{WebhookNetworks: []string{"185.71.76.0"}}); err == nil {
+		t.Fatal("NewYooKassaProvider accepted an address without a prefix length")
+	}
+}
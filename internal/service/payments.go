@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentCanceled  = "canceled"
+)
+
+var (
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrUntrustedWebhookSource  = errors.New("webhook came from an untrusted address")
+	ErrOrderNotPending         = errors.New("order is already paid or cancelled")
+	ErrPaymentNotFound         = errors.New("payment not found")
+)
+
+type Payment struct {
+	ID              string `json:"id"`
+	Status          string `json:"status"`
+	Amount          int    `json:"amount"`
+	ConfirmationURL string `json:"confirmation_url"`
+}
+
+type PaymentRequest struct {
+	OrderID     int
+	Amount      int
+	Description string
+	ReturnURL   string
+}
+
+// WebhookRequest is an incoming payment notification. Providers authenticate
+// it either by Signature or by the address it came from.
+type WebhookRequest struct {
+	Body      []byte
+	Signature string
+	RemoteIP  string
+}
+
+type PaymentEvent struct {
+	PaymentID string
+	Status    string
+}
+
+type PaymentProvider interface {
+	CreatePayment(ctx context.Context, request PaymentRequest) (Payment, error)
+	GetPayment(ctx context.Context, paymentId string) (Payment, error)
+	Refund(ctx context.Context, paymentId string, amount int, idempotenceKey string) error
+	ParseWebhook(request WebhookRequest) (PaymentEvent, error)
+}
+
+func verifyWebhookSignature(secret string, body []byte, signature string) error {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
+
+type PaymentsService struct {
+	repo       *repository.Repository
+	provider   PaymentProvider
+	returnURL  string
+	paymentTTL time.Duration
+}
+
+func NewPaymentsService(repo *repository.Repository, provider PaymentProvider, returnURL string, paymentTTL time.Duration) *PaymentsService {
+	return &PaymentsService{
+		repo:       repo,
+		provider:   provider,
+		returnURL:  returnURL,
+		paymentTTL: paymentTTL,
+	}
+}
+
+// CreateOrderPayment returns the order's payment, creating one only when the
+// order has none yet or its payment was cancelled or expired. Replacing a live
+// payment would leave it unmatched to the order if the customer paid it.
+func (s *PaymentsService) CreateOrderPayment(ctx context.Context, userId int, orderId int) (Payment, error) {
+	order, err := s.getOrder(orderId)
+	if err != nil {
+		return Payment{}, err
+	}
+	if order.UserID != userId {
+		return Payment{}, ErrOrderAccessDenied
+	}
+	if order.Status != PendingOrderStatus {
+		return Payment{}, ErrOrderNotPending
+	}
+	if order.PaymentID != "" {
+		payment, err := s.provider.GetPayment(ctx, order.PaymentID)
+		if err != nil {
+			return Payment{}, err
+		}
+		if payment.Status == PaymentSucceeded {
+			if err := s.confirmOrderPayment(ctx, order); err != nil {
+				return Payment{}, err
+			}
+		}
+		if payment.Status != PaymentCanceled {
+			return payment, nil
+		}
+	}
+	payment, err := s.provider.CreatePayment(ctx, PaymentRequest{
+		OrderID:     order.ID,
+		Amount:      order.OrderPrice + order.DeliveryPrice,
+		Description: fmt.Sprintf("Order #%d", order.ID),
+		ReturnURL:   s.returnURL,
+	})
+	if err != nil {
+		return Payment{}, err
+	}
+	err = s.repo.Orders.SetPaymentId(order.ID, order.PaymentID, payment.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A concurrent request replaced the payment first; the one created here
+		// is never shown to the customer and expires unpaid.
+		order, err = s.getOrder(orderId)
+		if err != nil {
+			return Payment{}, err
+		}
+		return s.provider.GetPayment(ctx, order.PaymentID)
+	}
+	if err != nil {
+		return Payment{}, err
+	}
+	return payment, nil
+}
+
+func (s *PaymentsService) SyncOrderPayment(ctx context.Context, userId int, orderId int) (string, error) {
+	order, err := s.getOrder(orderId)
+	if err != nil {
+		return "", err
+	}
+	if order.UserID != userId {
+		return "", ErrOrderAccessDenied
+	}
+	if order.PaymentID == "" {
+		return "", ErrPaymentNotFound
+	}
+	payment, err := s.provider.GetPayment(ctx, order.PaymentID)
+	if err != nil {
+		return "", err
+	}
+	if payment.Status == PaymentSucceeded && order.Status == PendingOrderStatus {
+		if err := s.confirmOrderPayment(ctx, order); err != nil {
+			return "", err
+		}
+	}
+	return payment.Status, nil
+}
+
+func (s *PaymentsService) HandleWebhook(ctx context.Context, request WebhookRequest) error {
+	event, err := s.provider.ParseWebhook(request)
+	if err != nil {
+		return err
+	}
+	if event.Status != PaymentSucceeded {
+		return nil
+	}
+	order, err := s.repo.Orders.GetOrderByPaymentId(event.PaymentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPaymentNotFound
+		}
+		return err
+	}
+	cancelledUnpaid := order.Status == CancelledOrderStatus && !order.StockCommitted
+	if order.Status != PendingOrderStatus && !cancelledUnpaid {
+		return nil
+	}
+	payment, err := s.provider.GetPayment(ctx, event.PaymentID)
+	if err != nil {
+		return err
+	}
+	if payment.Status != PaymentSucceeded {
+		return nil
+	}
+	if cancelledUnpaid {
+		logrus.Infof("Refunding payment %s of order %d cancelled before payment", event.PaymentID, order.ID)
+		err := s.repo.Refunds.CreateRefund(model.Refund{
+			OrderID:   order.ID,
+			PaymentID: event.PaymentID,
+			Amount:    order.OrderPrice + order.DeliveryPrice,
+			Reason:    UnfulfilledRefundReason,
+		})
+		if err != nil {
+			return err
+		}
+		return sendRefunds(ctx, s.repo, s.provider, order.ID)
+	}
+	return s.confirmOrderPayment(ctx, order)
+}
+
+// confirmOrderPayment moves the paid order out of pending and commits its stock.
+// If the reservation expired and the stock was sold meanwhile, the order cannot
+// be fulfilled, so it is cancelled and the payment refunded.
+func (s *PaymentsService) confirmOrderPayment(ctx context.Context, order model.Order) error {
+	if err := checkOrderTransition(order.Type, order.Status, CreatedOrderStatus, systemActor); err != nil {
+		return err
+	}
+	err := s.repo.Orders.SetOrderStatus(order.ID, order.Status, CreatedOrderStatus, 0)
+	if errors.Is(err, repository.ErrOrderStatusChanged) {
+		return nil
+	}
+	if !errors.Is(err, repository.ErrNotEnoughStock) {
+		return err
+	}
+	logrus.Infof("Order %d is paid but out of stock, cancelling and refunding it", order.ID)
+	err = s.repo.Orders.CancelOrder(order.ID, order.Status, 0, model.Refund{
+		Amount: order.OrderPrice + order.DeliveryPrice,
+		Reason: UnfulfilledRefundReason,
+	})
+	if errors.Is(err, repository.ErrOrderStatusChanged) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return sendRefunds(ctx, s.repo, s.provider, order.ID)
+}
+
+func (s *PaymentsService) CancelUnpaidOrders(ctx context.Context) error {
+	orders, err := s.repo.Orders.GetUnpaidOrders(time.Now().Add(-s.paymentTTL))
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if order.PaymentID != "" {
+			payment, err := s.provider.GetPayment(ctx, order.PaymentID)
+			if err != nil {
+				logrus.Errorf("error getting payment status of order %d: %s", order.ID, err.Error())
+				continue
+			}
+			if payment.Status == PaymentSucceeded {
+				if err := s.confirmOrderPayment(ctx, order); err != nil {
+					logrus.Errorf("error confirming payment of order %d: %s", order.ID, err.Error())
+				}
+				continue
+			}
+		}
+		err := s.repo.Orders.CancelOrder(order.ID, order.Status, 0, model.Refund{})
+		if err != nil && !errors.Is(err, repository.ErrOrderStatusChanged) {
+			logrus.Errorf("error cancelling unpaid order %d: %s", order.ID, err.Error())
+			continue
+		}
+		logrus.Infof("Unpaid order %d cancelled", order.ID)
+	}
+	return nil
+}
+
+func (s *PaymentsService) getOrder(orderId int) (model.Order, error) {
+	order, err := s.repo.Orders.GetOrder(orderId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Order{}, ErrOrderNotFound
+		}
+		return model.Order{}, err
+	}
+	return order, nil
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+const (
+	testWebhookSecret = "test-webhook-secret"
+	testPaymentTTL    = 30 * time.Minute
+)
+
+// fakeOrdersRepo keeps orders, warehouse stock and refunds in memory the way
+// OrdersPostgres and RefundsPostgres do: pending orders hold a reservation,
+// leaving pending commits their stock, cancelling returns committed stock or
+// drops the reservation, and a refund is recorded with the cancellation.
+type fakeOrdersRepo struct {
+	repository.Orders
+	repository.Refunds
+	orders         map[int]*model.Order
+	stock          map[string]int
+	reserved       map[int]bool
+	refunds        []*model.Refund
+	completeErrors int
+}
+
+func newFakeOrdersRepo(stock map[string]int) *fakeOrdersRepo {
+	return &fakeOrdersRepo{
+		orders:   make(map[int]*model.Order),
+		stock:    stock,
+		reserved: make(map[int]bool),
+	}
+}
+
+func stockKey(productId int, size string) string {
+	return fmt.Sprintf("%d/%s", productId, size)
+}
+
+func (r *fakeOrdersRepo) addOrder(order model.Order) {
+	r.orders[order.ID] = &order
+	r.reserved[order.ID] = order.Status == PendingOrderStatus
+}
+
+func (r *fakeOrdersRepo) GetOrder(orderId int) (model.Order, error) {
+	order, ok := r.orders[orderId]
+	if !ok {
+		return model.Order{}, sql.ErrNoRows
+	}
+	return *order, nil
+}
+
+func (r *fakeOrdersRepo) GetOrderByPaymentId(paymentId string) (model.Order, error) {
+	for _, order := range r.orders {
+		if order.PaymentID == paymentId {
+			return *order, nil
+		}
+	}
+	return model.Order{}, sql.ErrNoRows
+}
+
+func (r *fakeOrdersRepo) GetUnpaidOrders(createdBefore time.Time) ([]model.Order, error) {
+	var orders []model.Order
+	for _, order := range r.orders {
+		if order.Status == PendingOrderStatus && order.CreatedAt.Before(createdBefore) {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
+
+func (r *fakeOrdersRepo) SetPaymentId(orderId int, oldPaymentId string, paymentId string) error {
+	order := r.orders[orderId]
+	if order.PaymentID != oldPaymentId {
+		return sql.ErrNoRows
+	}
+	order.PaymentID = paymentId
+	return nil
+}
+
+func (r *fakeOrdersRepo) SetOrderStatus(orderId int, fromStatus string, status string, changedBy int) error {
+	order := r.orders[orderId]
+	if order.Status != fromStatus {
+		return repository.ErrOrderStatusChanged
+	}
+	if fromStatus == PendingOrderStatus {
+		for _, product := range order.OrderedProducts {
+			if r.stock[stockKey(product.ProductID, product.Size)] < product.Amount {
+				return repository.ErrNotEnoughStock
+			}
+		}
+		for _, product := range order.OrderedProducts {
+			r.stock[stockKey(product.ProductID, product.Size)] -= product.Amount
+		}
+		r.reserved[orderId] = false
+		order.StockCommitted = true
+	}
+	order.Status = status
+	return nil
+}
+
+func (r *fakeOrdersRepo) CancelOrder(orderId int, fromStatus string, changedBy int, refund model.Refund) error {
+	order := r.orders[orderId]
+	if order.Status != fromStatus {
+		return repository.ErrOrderStatusChanged
+	}
+	if order.StockCommitted {
+		for _, product := range order.OrderedProducts {
+			r.stock[stockKey(product.ProductID, product.Size)] += product.Amount
+		}
+	}
+	r.reserved[orderId] = false
+	order.Status = CancelledOrderStatus
+	if refund.Amount > 0 {
+		refund.OrderID = orderId
+		refund.PaymentID = order.PaymentID
+		return r.CreateRefund(refund)
+	}
+	return nil
+}
+
+// CreateRefund skips a second unfulfilled refund of the same payment, like the
+// unique index on refunds.
+func (r *fakeOrdersRepo) CreateRefund(refund model.Refund) error {
+	for _, existing := range r.refunds {
+		if refund.Reason == UnfulfilledRefundReason && existing.Reason == UnfulfilledRefundReason && existing.PaymentID == refund.PaymentID {
+			return nil
+		}
+	}
+	refund.ID = len(r.refunds) + 1
+	r.refunds = append(r.refunds, &refund)
+	return nil
+}
+
+func (r *fakeOrdersRepo) GetPendingRefunds(orderId int) ([]model.Refund, error) {
+	var refunds []model.Refund
+	for _, refund := range r.refunds {
+		if refund.RefundedAt == nil && (orderId == 0 || refund.OrderID == orderId) {
+			refunds = append(refunds, *refund)
+		}
+	}
+	return refunds, nil
+}
+
+// CompleteRefund fails completeErrors times first, as if the database went
+// away after the provider accepted the refund.
+func (r *fakeOrdersRepo) CompleteRefund(refundId int) error {
+	if r.completeErrors > 0 {
+		r.completeErrors--
+		return errors.New("connection lost")
+	}
+	now := time.Now()
+	r.refunds[refundId-1].RefundedAt = &now
+	return nil
+}
+
+func newPaymentsTestService(stock map[string]int) (*PaymentsService, *fakeOrdersRepo, *FakePaymentProvider) {
+	repo := newFakeOrdersRepo(stock)
+	provider := NewFakePaymentProvider(testWebhookSecret, false)
+	s := NewPaymentsService(&repository.Repository{Orders: repo, Refunds: repo}, provider, "http://localhost:3000/orders", testPaymentTTL)
+	return s, repo, provider
+}
+
+func testPendingOrder(id int, createdAt time.Time) model.Order {
+	return model.Order{
+		ID:            id,
+		Type:          PickupOrderType,
+		Status:        PendingOrderStatus,
+		UserID:        1,
+		OrderPrice:    5000,
+		DeliveryPrice: 0,
+		CreatedAt:     createdAt,
+		OrderedProducts: []model.OrderedProduct{
+			{ProductID: 7, Size: "M", Amount: 2, Price: 2500},
+		},
+	}
+}
+
+func signedWebhook(t *testing.T, paymentId string, status string) WebhookRequest {
+	t.Helper()
+	body, err := json.Marshal(yooKassaWebhook{
+		Event:  "payment." + status,
+		Object: yooKassaPayment{ID: paymentId, Status: status},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write(body)
+	return WebhookRequest{Body: body, Signature: hex.EncodeToString(mac.Sum(nil))}
+}
+
+func TestWebhookConfirmsPaidOrder(t *testing.T) {
+	s, repo, _ := newPaymentsTestService(map[string]int{stockKey(7, "M"): 5})
+	repo.addOrder(testPendingOrder(1, time.Now()))
+	payment, err := s.CreateOrderPayment(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("CreateOrderPayment: %s", err)
+	}
+	if payment.Amount != 5000 || payment.Status != PaymentPending {
+		t.Fatalf("payment = %+v, want a pending payment of 5000", payment)
+	}
+	if again, err := s.CreateOrderPayment(context.Background(), 1, 1); err != nil || again.ID != payment.ID {
+		t.Fatalf("second CreateOrderPayment = %+v, %v, want the live payment %s", again, err, payment.ID)
+	}
+
+	webhook := signedWebhook(t, payment.ID, PaymentSucceeded)
+	if err := s.HandleWebhook(context.Background(), webhook); err != nil {
+		t.Fatalf("HandleWebhook: %s", err)
+	}
+	order := repo.orders[1]
+	if order.Status != CreatedOrderStatus || !order.StockCommitted {
+		t.Fatalf("order status = %s, stock committed = %t, want %s and committed", order.Status, order.StockCommitted, CreatedOrderStatus)
+	}
+	if stock := repo.stock[stockKey(7, "M")]; stock != 3 {
+		t.Fatalf("stock after payment = %d, want 3", stock)
+	}
+
+	if err := s.HandleWebhook(context.Background(), webhook); err != nil {
+		t.Fatalf("repeated HandleWebhook: %s", err)
+	}
+	if stock := repo.stock[stockKey(7, "M")]; stock != 3 {
+		t.Fatalf("stock after a repeated webhook = %d, want 3", stock)
+	}
+}
+
+func TestWebhookRejectsBadSignature(t *testing.T) {
+	s, repo, _ := newPaymentsTestService(map[string]int{stockKey(7, "M"): 5})
+	repo.addOrder(testPendingOrder(1, time.Now()))
+	payment, err := s.CreateOrderPayment(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("CreateOrderPayment: %s", err)
+	}
+	webhook := signedWebhook(t, payment.ID, PaymentSucceeded)
+	webhook.Signature = hex.EncodeToString([]byte("forged"))
+	if err := s.HandleWebhook(context.Background(), webhook); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("HandleWebhook with a bad signature = %v, want %v", err, ErrInvalidWebhookSignature)
+	}
+	if repo.orders[1].Status != PendingOrderStatus {
+		t.Fatalf("order status = %s after a forged webhook, want %s", repo.orders[1].Status, PendingOrderStatus)
+	}
+}
+
+func TestCancelUnpaidOrders(t *testing.T) {
+	s, repo, provider := newPaymentsTestService(map[string]int{stockKey(7, "M"): 5})
+	expired := time.Now().Add(-2 * testPaymentTTL)
+	repo.addOrder(testPendingOrder(1, expired))
+	repo.addOrder(testPendingOrder(2, expired))
+	repo.addOrder(testPendingOrder(3, expired))
+	repo.addOrder(testPendingOrder(4, time.Now()))
+	unpaid, err := s.CreateOrderPayment(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatalf("CreateOrderPayment: %s", err)
+	}
+	paid, err := s.CreateOrderPayment(context.Background(), 1, 3)
+	if err != nil {
+		t.Fatalf("CreateOrderPayment: %s", err)
+	}
+	// The webhook for the paid order was lost; the sweep must notice it.
+	if err := provider.SetPaymentStatus(paid.ID, PaymentSucceeded); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.CancelUnpaidOrders(context.Background()); err != nil {
+		t.Fatalf("CancelUnpaidOrders: %s", err)
+	}
+	for _, orderId := range []int{1, 2} {
+		if status := repo.orders[orderId].Status; status != CancelledOrderStatus {
+			t.Errorf("unpaid order %d status = %s, want %s", orderId, status, CancelledOrderStatus)
+		}
+		if repo.reserved[orderId] {
+			t.Errorf("unpaid order %d still holds its stock reservation", orderId)
+		}
+	}
+	if status := repo.orders[3].Status; status != CreatedOrderStatus {
+		t.Errorf("paid order status = %s, want %s", status, CreatedOrderStatus)
+	}
+	if status := repo.orders[4].Status; status != PendingOrderStatus || !repo.reserved[4] {
+		t.Errorf("fresh order status = %s, reserved = %t, want it left pending", status, repo.reserved[4])
+	}
+	if stock := repo.stock[stockKey(7, "M")]; stock != 3 {
+		t.Errorf("stock = %d, want 3 with only the paid order committed", stock)
+	}
+	if len(repo.refunds) != 0 {
+		t.Errorf("recorded %d refunds for unpaid orders", len(repo.refunds))
+	}
+
+	// The customer pays the cancelled order after all: the money goes back once.
+	if err := provider.SetPaymentStatus(unpaid.ID, PaymentSucceeded); err != nil {
+		t.Fatal(err)
+	}
+	webhook := signedWebhook(t, unpaid.ID, PaymentSucceeded)
+	for i := 0; i < 2; i++ {
+		if err := s.HandleWebhook(context.Background(), webhook); err != nil {
+			t.Fatalf("HandleWebhook for a cancelled order: %s", err)
+		}
+	}
+	if len(repo.refunds) != 1 || repo.refunds[0].RefundedAt == nil || repo.refunds[0].Reason != UnfulfilledRefundReason {
+		t.Fatalf("refunds = %+v, want one completed unfulfilled refund", repo.refunds)
+	}
+	if payment, _ := provider.GetPayment(context.Background(), unpaid.ID); payment.Amount != 0 {
+		t.Fatalf("late payment = %+v, want it fully refunded", payment)
+	}
+}
+
+func TestPaidOrderOutOfStockIsRefunded(t *testing.T) {
+	s, repo, provider := newPaymentsTestService(map[string]int{stockKey(7, "M"): 1})
+	repo.addOrder(testPendingOrder(1, time.Now()))
+	payment, err := s.CreateOrderPayment(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("CreateOrderPayment: %s", err)
+	}
+	if err := s.HandleWebhook(context.Background(), signedWebhook(t, payment.ID, PaymentSucceeded)); err != nil {
+		t.Fatalf("HandleWebhook: %s", err)
+	}
+	if status := repo.orders[1].Status; status != CancelledOrderStatus {
+		t.Fatalf("order status = %s, want %s", status, CancelledOrderStatus)
+	}
+	if stock := repo.stock[stockKey(7, "M")]; stock != 1 {
+		t.Fatalf("stock = %d, want it untouched", stock)
+	}
+	if len(repo.refunds) != 1 || repo.refunds[0].Amount != 5000 || repo.refunds[0].RefundedAt == nil {
+		t.Fatalf("refunds = %+v, want one completed refund of 5000", repo.refunds)
+	}
+	if payment, _ := provider.GetPayment(context.Background(), payment.ID); payment.Status != PaymentCanceled {
+		t.Fatalf("payment status = %s, want %s", payment.Status, PaymentCanceled)
+	}
+}
+
+func TestRefundIsSentOnceWhenCompletionFails(t *testing.T) {
+	s, repo, provider := newPaymentsTestService(map[string]int{stockKey(7, "M"): 5})
+	order := testPendingOrder(1, time.Now())
+	order.OrderedProducts[0].Amount = 1
+	order.OrderPrice = 2500
+	repo.addOrder(order)
+	payment, err := s.CreateOrderPayment(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatalf("CreateOrderPayment: %s", err)
+	}
+	if err := s.HandleWebhook(context.Background(), signedWebhook(t, payment.ID, PaymentSucceeded)); err != nil {
+		t.Fatalf("HandleWebhook: %s", err)
+	}
+	if err := repo.CancelOrder(1, CreatedOrderStatus, 1, model.Refund{Amount: 1000, Reason: CancelRefundReason}); err != nil {
+		t.Fatal(err)
+	}
+
+	repo.completeErrors = 1
+	if err := s.SendPendingRefunds(context.Background()); err == nil {
+		t.Fatal("SendPendingRefunds succeeded although the refund wasn't recorded as sent")
+	}
+	if err := s.SendPendingRefunds(context.Background()); err != nil {
+		t.Fatalf("retried SendPendingRefunds: %s", err)
+	}
+	if err := s.SendPendingRefunds(context.Background()); err != nil {
+		t.Fatalf("SendPendingRefunds with nothing pending: %s", err)
+	}
+	if payment, _ := provider.GetPayment(context.Background(), payment.ID); payment.Amount != 1500 {
+		t.Fatalf("payment amount after the refund = %d, want 1500 refunded once", payment.Amount)
+	}
+	if repo.refunds[0].RefundedAt == nil {
+		t.Fatal("refund not marked as sent")
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import "fmt"
+
+type Permission string
+
+const (
+	ProductsWrite       Permission = "products:write"
+	ShopPointsWrite     Permission = "shop_points:write"
+	PromoCodesWrite     Permission = "promo_codes:write"
+	OrdersReadAll       Permission = "orders:read_all"
+	OrdersFulfil        Permission = "orders:fulfil"
+	OrdersRefund        Permission = "orders:refund"
+	OrdersOverride      Permission = "orders:override"
+	UsersManage         Permission = "users:manage_roles"
+	ReviewsModerate     Permission = "reviews:moderate"
+	NotificationsManage Permission = "notifications:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	adminRole: {
+		ProductsWrite,
+		ShopPointsWrite,
+		PromoCodesWrite,
+		OrdersReadAll,
+		OrdersFulfil,
+		OrdersRefund,
+		OrdersOverride,
+		UsersManage,
+		ReviewsModerate,
+		NotificationsManage,
+	},
+	buyerRole: {
+		OrdersReadAll,
+		OrdersFulfil,
+		OrdersRefund,
+	},
+	customerRole: {},
+}
+
+func RolePermissions(role string) []Permission {
+	return rolePermissions[role]
+}
+
+func RoleHasPermission(role string, permission Permission) bool {
+	return hasPermission(rolePermissions[role], permission)
+}
+
+func hasPermission(permissions []Permission, permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderActor is the caller of an order action with the permissions granted to
+// them for the request. Order checks use these instead of the stored role, so
+// the route's authorization policy applies to them as well.
+type OrderActor struct {
+	UserID      int
+	Permissions []Permission
+	system      bool
+}
+
+// systemActor changes orders on behalf of payment and delivery workers.
+var systemActor = OrderActor{system: true}
+
+func (a OrderActor) Can(permission Permission) bool {
+	return hasPermission(a.Permissions, permission)
+}
+
+func (a OrderActor) String() string {
+	if a.system {
+		return systemRole
+	}
+	return fmt.Sprintf("user %d", a.UserID)
+}
@@ -1,30 +1,39 @@
 package service
 
 import (
+	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lavatee/dresscode_backend/internal/model"
 	"github.com/lavatee/dresscode_backend/internal/repository"
 )
 
+const defaultProductWeight = 500
+
 var (
-	ErrUserNotAdmin = errors.New("user is not admin")
 	ErrUserNotBuyer = errors.New("user is not buyer")
+
+	ErrProductNotFound      = errors.New("product not found")
+	ErrProductPriceNotFound = errors.New("product price not found")
+	ErrInvalidProductPrice  = errors.New("price must be positive, compare-at price must exceed it and the period must not be empty")
 )
 
 type ProductsService struct {
-	repo *repository.Repository
+	repo     *repository.Repository
+	delivery DeliveryProvider
 }
 
-func NewProductsService(repo *repository.Repository) *ProductsService {
+func NewProductsService(repo *repository.Repository, delivery DeliveryProvider) *ProductsService {
 	return &ProductsService{
-		repo: repo,
+		repo:     repo,
+		delivery: delivery,
 	}
 }
 
-func (s *ProductsService) CreateProduct(userId int, product model.Product) (int, error) {
-	if !s.repo.Auth.IsAdmin(userId) {
-		return 0, ErrUserNotAdmin
+func (s *ProductsService) CreateProduct(product model.Product) (int, error) {
+	if product.Weight <= 0 {
+		product.Weight = defaultProductWeight
 	}
 	return s.repo.Products.CreateProduct(product)
 }
@@ -52,29 +61,14 @@ func (s *ProductsService) GetProduct(productId int, userId int) (model.Product,
 	return product, nil
 }
 
-func (s *ProductsService) GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, page int, userId int) ([]model.Product, error) {
-	return s.repo.Products.GetProducts(collectionId, category, colors, sizes, minPrice, maxPrice, page, userId)
+func (s *ProductsService) GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, onSale bool, page int, userId int) ([]model.Product, error) {
+	return s.repo.Products.GetProducts(collectionId, category, colors, sizes, minPrice, maxPrice, onSale, page, userId)
 }
 
-func (s *ProductsService) DeleteProduct(userId int, productId int) error {
-	if !s.repo.Auth.IsAdmin(userId) {
-		return ErrUserNotAdmin
-	}
+func (s *ProductsService) DeleteProduct(productId int) error {
 	return s.repo.Products.DeleteProduct(productId)
 }
 
-func (s *ProductsService) AddProductToCart(userId int, productId int, size string, amount int) error {
-	return s.repo.Products.AddProductToCart(userId, productId, size, amount)
-}
-
-func (s *ProductsService) RemoveProductFromCart(userId int, productId int) error {
-	return s.repo.Products.RemoveProductFromCart(userId, productId)
-}
-
-func (s *ProductsService) GetProductsInCart(userId int) ([]model.ProductInCart, error) {
-	return s.repo.Products.GetProductsInCart(userId)
-}
-
 func (s *ProductsService) AddProductToLiked(userId int, productId int) error {
 	return s.repo.Products.AddProductToLiked(userId, productId)
 }
@@ -87,17 +81,11 @@ func (s *ProductsService) GetLikedProducts(userId int) ([]model.Product, error)
 	return s.repo.Products.GetLikedProducts(userId)
 }
 
-func (s *ProductsService) ChangeProductSizesAmount(userId int, sizesMap map[int]int) error {
-	if !s.repo.Auth.IsAdmin(userId) {
-		return ErrUserNotAdmin
-	}
+func (s *ProductsService) ChangeProductSizesAmount(sizesMap map[int]int) error {
 	return s.repo.Products.ChangeProductSizesAmount(sizesMap)
 }
 
-func (s *ProductsService) UpdateProductSizes(userId int, productId int, removedSizes []int, addedSizes []model.Size) error {
-	if !s.repo.Auth.IsAdmin(userId) {
-		return ErrUserNotAdmin
-	}
+func (s *ProductsService) UpdateProductSizes(productId int, removedSizes []int, addedSizes []model.Size) error {
 	return s.repo.Products.UpdateProductSizes(productId, removedSizes, addedSizes)
 }
 
@@ -105,10 +93,7 @@ func (s *ProductsService) GetProductSizes(productId int) ([]model.Size, error) {
 	return s.repo.Products.GetProductSizes(productId)
 }
 
-func (s *ProductsService) CreateCollection(userId int, collection model.Collection) (int, error) {
-	if !s.repo.Auth.IsAdmin(userId) {
-		return 0, ErrUserNotAdmin
-	}
+func (s *ProductsService) CreateCollection(collection model.Collection) (int, error) {
 	return s.repo.Products.CreateCollection(collection)
 }
 
@@ -119,3 +104,39 @@ func (s *ProductsService) GetCollections() ([]model.Collection, error) {
 func (s *ProductsService) SearchProducts(userId int, userQuery string) ([]model.Product, error) {
 	return s.repo.Products.SearchProducts(userId, userQuery)
 }
+
+func (s *ProductsService) CreateProductPrice(price model.ProductPrice) (int, error) {
+	if price.EffectiveFrom.IsZero() {
+		price.EffectiveFrom = time.Now()
+	}
+	if price.Price <= 0 || (price.CompareAtPrice != nil && *price.CompareAtPrice <= price.Price) || (price.EffectiveTo != nil && !price.EffectiveFrom.Before(*price.EffectiveTo)) {
+		return 0, ErrInvalidProductPrice
+	}
+	if _, err := s.getProduct(price.ProductID); err != nil {
+		return 0, err
+	}
+	return s.repo.Products.CreateProductPrice(price)
+}
+
+func (s *ProductsService) GetProductPrices(productId int) ([]model.ProductPrice, error) {
+	if _, err := s.getProduct(productId); err != nil {
+		return nil, err
+	}
+	return s.repo.Products.GetProductPrices(productId)
+}
+
+func (s *ProductsService) DeleteProductPrice(productId int, priceId int) error {
+	err := s.repo.Products.DeleteProductPrice(productId, priceId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrProductPriceNotFound
+	}
+	return err
+}
+
+func (s *ProductsService) getProduct(productId int) (model.Product, error) {
+	product, err := s.repo.Products.GetProduct(productId, 0)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Product{}, ErrProductNotFound
+	}
+	return product, err
+}
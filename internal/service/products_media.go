@@ -3,7 +3,6 @@ package service
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -32,10 +31,7 @@ func (s *ProductsMediaService) getMediaURL(key string) string {
 	return fmt.Sprintf("https://5a1bc5f7-b5c2-4a61-969a-beacbd4d7999.selstorage.ru/%s", key)
 }
 
-func (s *ProductsMediaService) UploadOneProductMedia(ctx context.Context, userId int, productID int, media model.ProductMedia, fileName string, isProductMain bool, file multipart.File) (int, string, error) {
-	if !s.repo.Auth.IsAdmin(userId) {
-		return 0, "", errors.New("user is not admin")
-	}
+func (s *ProductsMediaService) UploadOneProductMedia(ctx context.Context, productID int, media model.ProductMedia, fileName string, isProductMain bool, file multipart.File) (int, string, error) {
 	media.ProductID = productID
 	media.URL = s.getMediaURL(fileName)
 	id, err := s.repo.ProductsMedia.CreateOneProductMedia(media, isProductMain)
@@ -58,9 +54,6 @@ func (s *ProductsMediaService) GetProductMedia(productID int) ([]model.ProductMe
 	return s.repo.ProductsMedia.GetProductMedia(productID)
 }
 
-func (s *ProductsMediaService) DeleteOneProductMedia(userId int, mediaId int) error {
-	if !s.repo.Auth.IsAdmin(userId) {
-		return errors.New("user is not admin")
-	}
+func (s *ProductsMediaService) DeleteOneProductMedia(mediaId int) error {
 	return s.repo.ProductsMedia.DeleteOneProductMedia(mediaId)
 }
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+const emailChangeTokenType = "email_change"
+
+var (
+	ErrWrongPassword = errors.New("current password is incorrect")
+	ErrSameEmail     = errors.New("new email matches the current one")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrPasswordSet   = errors.New("account has a password, confirm the action with it")
+)
+
+func (s *AuthService) UpdateUser(userId int, update model.UserUpdate) (model.User, error) {
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		update.Name = &name
+	}
+	if update.Phone != nil {
+		phone := strings.TrimSpace(*update.Phone)
+		update.Phone = &phone
+	}
+	if err := s.repo.Auth.UpdateUser(userId, update); err != nil {
+		return model.User{}, err
+	}
+	return s.repo.Auth.GetUser(userId)
+}
+
+// checkUserPassword confirms a sensitive action with the current password.
+// Accounts created through OAuth have none, so they pass the token from the
+// email sent by RequestReauth in its place.
+func (s *AuthService) checkUserPassword(userId int, password string) (model.User, error) {
+	user, err := s.repo.Auth.GetUser(userId)
+	if err != nil {
+		return model.User{}, err
+	}
+	if user.Password == "" {
+		userToken, err := s.useUserToken(password, reauthTokenType)
+		if err != nil {
+			return model.User{}, err
+		}
+		if userToken.UserID != userId {
+			return model.User{}, ErrInvalidUserToken
+		}
+		return user, nil
+	}
+	if ok, _ := checkPassword(user.Password, password); !ok {
+		return model.User{}, ErrWrongPassword
+	}
+	return user, nil
+}
+
+// RequestReauth emails a short-lived confirmation link to a user without a
+// password, whose token then stands in for the password in sensitive actions.
+func (s *AuthService) RequestReauth(ctx context.Context, userId int) error {
+	user, err := s.repo.Auth.GetUser(userId)
+	if err != nil {
+		return err
+	}
+	if user.Password != "" {
+		return ErrPasswordSet
+	}
+	token, err := s.newUserToken(userId, reauthTokenType, reauthTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, Email{
+		To:      user.Email,
+		Subject: "Подтверждение действия",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить изменение настроек аккаунта, перейдите по ссылке:\n%s\n\nСсылка действует 15 минут. Если вы ничего не меняли, просто проигнорируйте это письмо.\n",
+			user.Name, linkWithToken(s.links.ReauthURL, token)),
+	})
+}
+
+// RequestEmailChange keeps the new address as pending until the user follows
+// the link sent to it; until then the old address stays in use.
+func (s *AuthService) RequestEmailChange(ctx context.Context, userId int, email string, password string) error {
+	user, err := s.checkUserPassword(userId, password)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(user.Email, email) {
+		return ErrSameEmail
+	}
+	if _, err := s.repo.Auth.GetUserByEmail(email); err == nil {
+		return repository.ErrEmailTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err := s.repo.Auth.SetPendingEmail(userId, email); err != nil {
+		return err
+	}
+	token, err := s.newUserToken(userId, emailChangeTokenType, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, Email{
+		To:      email,
+		Subject: "Подтверждение новой почты",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы сменить почту аккаунта на %s, перейдите по ссылке:\n%s\n\nСсылка действует 24 часа. Если вы не меняли почту, просто проигнорируйте это письмо.\n",
+			user.Name, email, linkWithToken(s.links.ConfirmEmailChangeURL, token)),
+	})
+}
+
+func (s *AuthService) ConfirmEmailChange(token string) error {
+	userToken, err := s.useUserToken(token, emailChangeTokenType)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Auth.ConfirmPendingEmail(userToken.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidUserToken
+		}
+		return err
+	}
+	return nil
+}
+
+// ChangePassword signs the user out of every session and returns a fresh
+// token pair for the current one.
+func (s *AuthService) ChangePassword(userId int, currentPassword string, newPassword string, userAgent string, ip string) (string, string, error) {
+	if _, err := s.checkUserPassword(userId, currentPassword); err != nil {
+		return "", "", err
+	}
+	passwordHash, err := hashPassword(newPassword)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.repo.Auth.UpdatePasswordHash(userId, passwordHash); err != nil {
+		return "", "", err
+	}
+	if err := s.LogoutAll(userId); err != nil {
+		return "", "", err
+	}
+	return s.issueTokens(userId, uuid.NewString(), userAgent, ip)
+}
+
+func (s *AuthService) DeleteUser(userId int, password string) error {
+	if _, err := s.checkUserPassword(userId, password); err != nil {
+		return err
+	}
+	defer s.tokenVersions.invalidate(userId)
+	if err := s.repo.Auth.DeleteUser(userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+const (
+	PercentDiscountType = "percent"
+	FixedDiscountType   = "fixed"
+)
+
+var (
+	ErrPromoCodeNotFound      = errors.New("promo code not found")
+	ErrInvalidPromoCode       = errors.New("invalid promo code parameters")
+	ErrPromoCodeInactive      = errors.New("promo code is not active")
+	ErrPromoCodeMinOrderSum   = errors.New("order sum is less than promo code minimum")
+	ErrPromoCodeNotApplicable = errors.New("promo code does not apply to products in the cart")
+)
+
+type PromoCodesService struct {
+	repo *repository.Repository
+}
+
+func NewPromoCodesService(repo *repository.Repository) *PromoCodesService {
+	return &PromoCodesService{repo: repo}
+}
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func validatePromoCode(promoCode model.PromoCode) error {
+	if promoCode.Code == "" || promoCode.Value <= 0 || promoCode.MinOrderSum < 0 {
+		return ErrInvalidPromoCode
+	}
+	switch promoCode.DiscountType {
+	case PercentDiscountType:
+		if promoCode.Value > 100 {
+			return ErrInvalidPromoCode
+		}
+	case FixedDiscountType:
+	default:
+		return ErrInvalidPromoCode
+	}
+	if promoCode.ValidFrom != nil && promoCode.ValidTo != nil && !promoCode.ValidFrom.Before(*promoCode.ValidTo) {
+		return ErrInvalidPromoCode
+	}
+	if (promoCode.UsageLimit != nil && *promoCode.UsageLimit <= 0) || (promoCode.UsageLimitPerUser != nil && *promoCode.UsageLimitPerUser <= 0) {
+		return ErrInvalidPromoCode
+	}
+	return nil
+}
+
+func (s *PromoCodesService) CreatePromoCode(promoCode model.PromoCode) (int, error) {
+	promoCode.Code = normalizePromoCode(promoCode.Code)
+	if err := validatePromoCode(promoCode); err != nil {
+		return 0, err
+	}
+	return s.repo.PromoCodes.CreatePromoCode(promoCode)
+}
+
+func (s *PromoCodesService) GetPromoCodes() ([]model.PromoCode, error) {
+	return s.repo.PromoCodes.GetPromoCodes()
+}
+
+func (s *PromoCodesService) GetPromoCode(promoCodeId int) (model.PromoCode, error) {
+	promoCode, err := s.repo.PromoCodes.GetPromoCode(promoCodeId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.PromoCode{}, ErrPromoCodeNotFound
+	}
+	return promoCode, err
+}
+
+func (s *PromoCodesService) UpdatePromoCode(promoCodeId int, promoCode model.PromoCode) error {
+	if _, err := s.GetPromoCode(promoCodeId); err != nil {
+		return err
+	}
+	promoCode.Code = normalizePromoCode(promoCode.Code)
+	if err := validatePromoCode(promoCode); err != nil {
+		return err
+	}
+	return s.repo.PromoCodes.UpdatePromoCode(promoCodeId, promoCode)
+}
+
+func (s *PromoCodesService) DeletePromoCode(promoCodeId int) error {
+	return s.repo.PromoCodes.DeletePromoCode(promoCodeId)
+}
+
+func (s *PromoCodesService) ApplyPromoCode(userId int, code string) (model.PromoCodeApplication, error) {
+	productsInCart, err := s.repo.Products.GetProductsInCart(model.CartOwner{UserID: userId})
+	if err != nil {
+		return model.PromoCodeApplication{}, err
+	}
+	if len(productsInCart) == 0 {
+		return model.PromoCodeApplication{}, ErrEmptyCart
+	}
+	promoCode, discounts, err := resolvePromoCode(s.repo, userId, code, productsInCart, time.Now())
+	if err != nil {
+		return model.PromoCodeApplication{}, err
+	}
+	subtotal := cartSubtotal(productsInCart)
+	discount := sumDiscounts(discounts)
+	return model.PromoCodeApplication{
+		Code:           promoCode.Code,
+		Subtotal:       subtotal,
+		DiscountAmount: discount,
+		Total:          subtotal - discount,
+	}, nil
+}
+
+func resolvePromoCode(repo *repository.Repository, userId int, code string, productsInCart []model.ProductInCart, now time.Time) (model.PromoCode, []int, error) {
+	promoCode, err := repo.PromoCodes.GetPromoCodeByCode(normalizePromoCode(code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.PromoCode{}, nil, ErrPromoCodeNotFound
+		}
+		return model.PromoCode{}, nil, err
+	}
+	if !promoCode.IsActive || (promoCode.ValidFrom != nil && now.Before(*promoCode.ValidFrom)) || (promoCode.ValidTo != nil && !now.Before(*promoCode.ValidTo)) {
+		return model.PromoCode{}, nil, ErrPromoCodeInactive
+	}
+	if promoCode.UsageLimit != nil || promoCode.UsageLimitPerUser != nil {
+		total, byUser, err := repo.PromoCodes.CountPromoCodeUsages(promoCode.ID, userId)
+		if err != nil {
+			return model.PromoCode{}, nil, err
+		}
+		if (promoCode.UsageLimit != nil && total >= *promoCode.UsageLimit) || (promoCode.UsageLimitPerUser != nil && byUser >= *promoCode.UsageLimitPerUser) {
+			return model.PromoCode{}, nil, repository.ErrPromoCodeLimitReached
+		}
+	}
+	if cartSubtotal(productsInCart) < promoCode.MinOrderSum {
+		return model.PromoCode{}, nil, ErrPromoCodeMinOrderSum
+	}
+	discounts := promoCodeDiscounts(promoCode, productsInCart)
+	if sumDiscounts(discounts) == 0 {
+		return model.PromoCode{}, nil, ErrPromoCodeNotApplicable
+	}
+	return promoCode, discounts, nil
+}
+
+func promoCodeAppliesTo(promoCode model.PromoCode, productInCart model.ProductInCart) bool {
+	if promoCode.CollectionID != nil && *promoCode.CollectionID != productInCart.CollectionID {
+		return false
+	}
+	if promoCode.Category != nil && *promoCode.Category != productInCart.Category {
+		return false
+	}
+	return true
+}
+
+// promoCodeDiscounts returns the discount of every cart line. The total
+// discount is split between eligible lines in proportion to their sum, the
+// rounding remainder goes to the last eligible line.
+func promoCodeDiscounts(promoCode model.PromoCode, productsInCart []model.ProductInCart) []int {
+	discounts := make([]int, len(productsInCart))
+	eligibleSum := 0
+	lastEligible := -1
+	for i, productInCart := range productsInCart {
+		if promoCodeAppliesTo(promoCode, productInCart) {
+			eligibleSum += productInCart.Price * productInCart.Amount
+			lastEligible = i
+		}
+	}
+	if eligibleSum == 0 {
+		return discounts
+	}
+	total := promoCode.Value
+	if promoCode.DiscountType == PercentDiscountType {
+		total = eligibleSum * promoCode.Value / 100
+	}
+	if total > eligibleSum {
+		total = eligibleSum
+	}
+	distributed := 0
+	for i, productInCart := range productsInCart {
+		if !promoCodeAppliesTo(promoCode, productInCart) {
+			continue
+		}
+		if i == lastEligible {
+			discounts[i] = total - distributed
+			break
+		}
+		discounts[i] = total * productInCart.Price * productInCart.Amount / eligibleSum
+		distributed += discounts[i]
+	}
+	return discounts
+}
+
+func cartSubtotal(productsInCart []model.ProductInCart) int {
+	subtotal := 0
+	for _, productInCart := range productsInCart {
+		subtotal += productInCart.Price * productInCart.Amount
+	}
+	return subtotal
+}
+
+func sumDiscounts(discounts []int) int {
+	sum := 0
+	for _, discount := range discounts {
+		sum += discount
+	}
+	return sum
+}
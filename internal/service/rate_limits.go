@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrRateLimited = errors.New("too many requests, try again later")
+
+// RetryAfterError is returned when the caller has to wait before repeating
+// the request.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimit is a token bucket holding Requests tokens that refills completely
+// over Per. A zero limit means no limit.
+type RateLimit struct {
+	Requests int           `mapstructure:"requests"`
+	Per      time.Duration `mapstructure:"per"`
+}
+
+func (l RateLimit) enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l RateLimit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// RateLimitGroup sets the buckets for a group of routes: one per client IP and
+// one per account the request acts on.
+type RateLimitGroup struct {
+	IP      RateLimit `mapstructure:"ip"`
+	Account RateLimit `mapstructure:"account"`
+}
+
+// RateLimitStore keeps token buckets. The in-memory store is enough for a
+// single instance; several instances have to share the Postgres one.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type RateLimitsService struct {
+	store  RateLimitStore
+	groups map[string]RateLimitGroup
+}
+
+func NewRateLimitsService(store RateLimitStore, groups map[string]RateLimitGroup) *RateLimitsService {
+	return &RateLimitsService{
+		store:  store,
+		groups: groups,
+	}
+}
+
+// Allow takes a token from the IP bucket of the group and, when the account
+// is known, from its account bucket. Groups without config are not limited.
+func (s *RateLimitsService) Allow(ctx context.Context, group string, ip string, account string) error {
+	limits, ok := s.groups[group]
+	if !ok {
+		return nil
+	}
+	if err := s.take(ctx, fmt.Sprintf("%s:ip:%s", group, ip), limits.IP); err != nil {
+		return err
+	}
+	if account == "" {
+		return nil
+	}
+	return s.take(ctx, fmt.Sprintf("%s:%s", group, account), limits.Account)
+}
+
+func (s *RateLimitsService) take(ctx context.Context, key string, limit RateLimit) error {
+	if !limit.enabled() {
+		return nil
+	}
+	allowed, retryAfter, err := s.store.Take(ctx, key, limit)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &RetryAfterError{Err: ErrRateLimited, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (s *RateLimitsService) DeleteExpiredRateLimits(ctx context.Context) error {
+	deleted, err := s.store.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logrus.Infof("Deleted %d expired rate limit buckets", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRateLimitStore keeps buckets in the process. Like the Postgres store
+// it remembers for each key the moment its bucket will be full again.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refilledAt := s.buckets[key]
+	if refilledAt.Before(now) {
+		refilledAt = now
+	}
+	refilledAt = refilledAt.Add(limit.interval())
+	if wait := refilledAt.Sub(now) - limit.Per; wait > 0 {
+		return false, wait, nil
+	}
+	s.buckets[key] = refilledAt
+	return true, 0, nil
+}
+
+func (s *MemoryRateLimitStore) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for key, refilledAt := range s.buckets {
+		if refilledAt.Before(now) {
+			delete(s.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
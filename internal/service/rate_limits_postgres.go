@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+type PostgresRateLimitStore struct {
+	repo *repository.Repository
+}
+
+func NewPostgresRateLimitStore(repo *repository.Repository) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{repo: repo}
+}
+
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	return s.repo.RateLimits.TakeRateLimit(key, limit.interval(), limit.Per)
+}
+
+func (s *PostgresRateLimitStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return s.repo.RateLimits.DeleteExpiredRateLimits()
+}
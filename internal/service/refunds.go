@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lavatee/dresscode_backend/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CancelRefundReason      = "cancel"
+	ReturnRefundReason      = "return"
+	UnfulfilledRefundReason = "unfulfilled"
+)
+
+// sendRefunds sends the recorded refunds of the order, of all orders when
+// orderId is zero, to the provider. The idempotence key is derived from the
+// refund record, so resending a refund whose result was lost is safe.
+func sendRefunds(ctx context.Context, repo *repository.Repository, provider PaymentProvider, orderId int) error {
+	refunds, err := repo.Refunds.GetPendingRefunds(orderId)
+	if err != nil {
+		return err
+	}
+	for _, refund := range refunds {
+		key := fmt.Sprintf("refund-%d-%s", refund.ID, refund.PaymentID)
+		if err := provider.Refund(ctx, refund.PaymentID, refund.Amount, key); err != nil {
+			return fmt.Errorf("refund %d of order %d: %w", refund.ID, refund.OrderID, err)
+		}
+		if err := repo.Refunds.CompleteRefund(refund.ID); err != nil {
+			return err
+		}
+		logrus.Infof("Refunded %d of payment %s of order %d", refund.Amount, refund.PaymentID, refund.OrderID)
+	}
+	return nil
+}
+
+// SendPendingRefunds retries refunds that failed when the order was changed.
+func (s *PaymentsService) SendPendingRefunds(ctx context.Context) error {
+	return sendRefunds(ctx, s.repo, s.provider, 0)
+}
@@ -1,10 +1,15 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
+
 	"github.com/lavatee/dresscode_backend/internal/model"
 	"github.com/lavatee/dresscode_backend/internal/repository"
 )
 
+var ErrReviewNotFound = errors.New("review not found")
+
 type ReviewsService struct {
 	repo *repository.Repository
 }
@@ -21,12 +26,24 @@ func (s *ReviewsService) GetReviews(productId int) ([]model.Review, error) {
 	return s.repo.Reviews.GetReviews(productId)
 }
 
-func (s *ReviewsService) DeleteReview(reviewId int) error {
-	return s.repo.Reviews.DeleteReview(reviewId)
+// DeleteReview lets users delete their own reviews; moderators may delete any.
+func (s *ReviewsService) DeleteReview(reviewId int, userId int, moderate bool) error {
+	if moderate {
+		userId = 0
+	}
+	err := s.repo.Reviews.DeleteReview(reviewId, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrReviewNotFound
+	}
+	return err
 }
 
 func (s *ReviewsService) UpdateReview(reviewId int, review model.Review) error {
-	return s.repo.Reviews.UpdateReview(reviewId, review)
+	err := s.repo.Reviews.UpdateReview(reviewId, review)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrReviewNotFound
+	}
+	return err
 }
 
 func (s *ReviewsService) GetProductRating(productId int) (*float64, error) {
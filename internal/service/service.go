@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"mime/multipart"
+	"net/url"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/lavatee/dresscode_backend/internal/model"
@@ -12,67 +14,217 @@ import (
 
 type Auth interface {
 	CreateAdmin(name string, email string, password string) error
-	SignUp(user model.User) (int, error)
-	SignIn(email, password string) (string, string, error)
-	Refresh(refreshToken string) (string, string, error)
+	SignUp(ctx context.Context, user model.User) (int, error)
+	ResendVerificationEmail(ctx context.Context, userId int) error
+	VerifyEmail(token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(token string, password string) error
+	DeleteExpiredUserTokens(ctx context.Context) error
+	SignIn(email, password string, userAgent string, ip string) (SignInResult, error)
+	SignInTwoFactor(challengeToken string, code string, userAgent string, ip string) (SignInResult, error)
+	SetupTwoFactor(userId int, password string) (TwoFactorSetup, error)
+	ConfirmTwoFactor(userId int, code string, userAgent string, ip string) ([]string, string, string, error)
+	DisableTwoFactor(userId int, password string, code string) error
+	RegenerateRecoveryCodes(userId int, code string) ([]string, error)
+	TwoFactorRequired(role string) bool
+	DeleteStaleLoginFailures(ctx context.Context) error
+	StartOAuth(provider string) (string, string, error)
+	FinishOAuth(ctx context.Context, provider string, callback url.Values, verifier string, userAgent string, ip string) (SignInResult, error)
+	OAuthFrontendURL() string
+	GetUserIdentities(userId int) ([]model.Identity, error)
+	Refresh(refreshToken string, userAgent string, ip string) (string, string, error)
+	Logout(refreshToken string) error
+	LogoutAll(userId int) error
+	GetSessions(userId int) ([]model.Session, error)
+	RevokeSession(userId int, sessionId int) error
+	DeleteExpiredRefreshTokens(ctx context.Context) error
 	ParseToken(token string) (jwt.MapClaims, error)
 	NewAdmin(thisAdminId, newAdminId int) error
 	NewBuyer(thisAdminId, newBuyerId int) error
 	RemoveBuyer(thisAdminId, buyerId int) error
 	GetUserRole(userId int) (string, error)
 	GetUser(userId int) (model.User, error)
+	UpdateUser(userId int, update model.UserUpdate) (model.User, error)
+	RequestEmailChange(ctx context.Context, userId int, email string, password string) error
+	ConfirmEmailChange(token string) error
+	ChangePassword(userId int, currentPassword string, newPassword string, userAgent string, ip string) (string, string, error)
+	DeleteUser(userId int, password string) error
+	RequestReauth(ctx context.Context, userId int) error
+	JWKS() JWKS
 }
 
 type Products interface {
-	CreateProduct(userId int, product model.Product) (int, error)
+	CreateProduct(product model.Product) (int, error)
 	GetProduct(productId int, userId int) (model.Product, error)
-	GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, page int, userId int) ([]model.Product, error)
-	DeleteProduct(userId int, productId int) error
-	UpdateProductSizes(userId int, productId int, removedSizes []int, addedSizes []model.Size) error
+	GetProducts(collectionId int, category string, colors []string, sizes []string, minPrice int, maxPrice int, onSale bool, page int, userId int) ([]model.Product, error)
+	DeleteProduct(productId int) error
+	UpdateProductSizes(productId int, removedSizes []int, addedSizes []model.Size) error
 	GetProductSizes(productId int) ([]model.Size, error)
-	CreateCollection(userId int, collection model.Collection) (int, error)
+	CreateCollection(collection model.Collection) (int, error)
 	GetCollections() ([]model.Collection, error)
-	AddProductToCart(userId int, productId int, size string, amount int) error
-	RemoveProductFromCart(userId int, productId int) error
-	GetProductsInCart(userId int) ([]model.ProductInCart, error)
+	AddProductToCart(owner model.CartOwner, productId int, size string, amount int) error
+	RemoveProductFromCart(owner model.CartOwner, productId int, size string) error
+	UpdateCartLine(owner model.CartOwner, lineId int, amount int) error
+	RemoveCartLine(owner model.CartOwner, lineId int) error
+	GetProductsInCart(ctx context.Context, owner model.CartOwner, index int) (Cart, error)
 	AddProductToLiked(userId int, productId int) error
 	RemoveProductFromLiked(userId int, productId int) error
 	GetLikedProducts(userId int) ([]model.Product, error)
-	ChangeProductSizesAmount(userId int, sizesMap map[int]int) error
+	ChangeProductSizesAmount(sizesMap map[int]int) error
 	SearchProducts(userId int, userQuery string) ([]model.Product, error)
+	CreateProductPrice(price model.ProductPrice) (int, error)
+	GetProductPrices(productId int) ([]model.ProductPrice, error)
+	DeleteProductPrice(productId int, priceId int) error
 }
 
 type Orders interface {
+	StartCheckout(userId int) ([]model.StockReservation, error)
+	ReleaseExpiredReservations(ctx context.Context) error
+	CreateOrder(ctx context.Context, userId int, order model.Order) (model.Order, error)
+	GetUserOrders(userId int, status string, orderType string) ([]model.Order, error)
+	GetOrder(actor OrderActor, orderId int) (model.Order, error)
+	GetDeliveryOrders(filter model.OrdersFilter) ([]model.Order, error)
+	GetPickupOrders(filter model.OrdersFilter) ([]model.Order, error)
+	SetOrderStatus(actor OrderActor, orderId int, status string) error
+	GetOrderStatusHistory(actor OrderActor, orderId int) ([]model.OrderStatusChange, error)
+	CancelOrder(ctx context.Context, actor OrderActor, orderId int) error
+	ReturnOrderedProducts(ctx context.Context, actor OrderActor, orderId int, returnedProducts []model.ReturnedProduct) (bool, error)
+}
+
+type Payments interface {
+	CreateOrderPayment(ctx context.Context, userId int, orderId int) (Payment, error)
+	SyncOrderPayment(ctx context.Context, userId int, orderId int) (string, error)
+	HandleWebhook(ctx context.Context, request WebhookRequest) error
+	CancelUnpaidOrders(ctx context.Context) error
+	SendPendingRefunds(ctx context.Context) error
+}
+
+type Delivery interface {
+	Quote(ctx context.Context, userId int, index int) (DeliveryQuote, error)
+	CreateShipment(ctx context.Context, orderId int) (string, error)
+	SyncShipments(ctx context.Context) error
+}
+
+type Idempotency interface {
+	BeginRequest(scope string, key string, method string, path string, body []byte) (model.IdempotencyKey, bool, error)
+	CompleteRequest(id int, status int, body string) error
+	AbortRequest(id int) error
+	DeleteExpiredKeys(ctx context.Context) error
+}
+
+type ShopPoints interface {
+	CreateShopPoint(shopPoint model.ShopPoint) (int, error)
+	GetShopPoints(includeInactive bool) ([]model.ShopPoint, error)
+	GetShopPoint(shopPointId int) (model.ShopPoint, error)
+	UpdateShopPoint(shopPointId int, shopPoint model.ShopPoint) error
+	DeleteShopPoint(shopPointId int) error
+}
+
+type PromoCodes interface {
+	CreatePromoCode(promoCode model.PromoCode) (int, error)
+	GetPromoCodes() ([]model.PromoCode, error)
+	GetPromoCode(promoCodeId int) (model.PromoCode, error)
+	UpdatePromoCode(promoCodeId int, promoCode model.PromoCode) error
+	DeletePromoCode(promoCodeId int) error
+	ApplyPromoCode(userId int, code string) (model.PromoCodeApplication, error)
 }
 
 type ProductsMedia interface {
-	UploadOneProductMedia(ctx context.Context, userId int, productID int, media model.ProductMedia, fileName string, isProductMain bool, file multipart.File) (int, string, error)
-	DeleteOneProductMedia(userId int, mediaId int) error
+	UploadOneProductMedia(ctx context.Context, productID int, media model.ProductMedia, fileName string, isProductMain bool, file multipart.File) (int, string, error)
+	DeleteOneProductMedia(mediaId int) error
 	GetProductMedia(productID int) ([]model.ProductMedia, error)
 }
 
 type Reviews interface {
 	CreateReview(review model.Review) (int, error)
 	GetReviews(productId int) ([]model.Review, error)
-	DeleteReview(reviewId int) error
+	DeleteReview(reviewId int, userId int, moderate bool) error
 	UpdateReview(reviewId int, review model.Review) error
 	GetProductRating(productId int) (*float64, error)
 }
 
+type Notifications interface {
+	SendPendingNotifications(ctx context.Context) error
+	GetNotifications(filter model.NotificationsFilter) ([]model.Notification, error)
+	ResendNotification(notificationId int) error
+}
+
+type Addresses interface {
+	CreateAddress(userId int, address model.Address) (int, error)
+	GetAddresses(userId int) ([]model.Address, error)
+	GetAddress(userId int, addressId int) (model.Address, error)
+	UpdateAddress(userId int, addressId int, address model.Address) error
+	DeleteAddress(userId int, addressId int) error
+}
+
+type GuestCarts interface {
+	IssueGuestCartToken(guestCartId string) (string, string, error)
+	ParseGuestCartToken(token string) (string, error)
+	MergeGuestCart(guestCartId string, userId int) error
+	DeleteExpiredGuestCarts(ctx context.Context) error
+	GuestCartTTL() time.Duration
+}
+
+type RateLimits interface {
+	Allow(ctx context.Context, group string, ip string, account string) error
+	DeleteExpiredRateLimits(ctx context.Context) error
+}
+
 type Service struct {
 	Auth
 	Products
 	Orders
+	Payments
+	Delivery
+	Idempotency
+	ShopPoints
+	PromoCodes
 	ProductsMedia
 	Reviews
+	Notifications
+	Addresses
+	RateLimits
+	GuestCarts
+}
+
+type Deps struct {
+	SigningKeys             *KeySet
+	Mailer                  Mailer
+	AuthLinks               AuthLinks
+	TwoFactor               TwoFactorPolicy
+	LoginLockout            LoginLockoutPolicy
+	OAuth                   OAuthConfig
+	RateLimitStore          RateLimitStore
+	RateLimitGroups         map[string]RateLimitGroup
+	GuestCartTTL            time.Duration
+	S3                      *minio.Client
+	Bucket                  string
+	Payments                PaymentProvider
+	PaymentReturnURL        string
+	PaymentTTL              time.Duration
+	ReservationTTL          time.Duration
+	Delivery                DeliveryProvider
+	IdempotencyTTL          time.Duration
+	NotificationTemplates   *NotificationTemplates
+	NotificationOrdersURL   string
+	NotificationMaxAttempts int
 }
 
-func NewService(repo *repository.Repository, s3 *minio.Client, bucket string) *Service {
+func NewService(repo *repository.Repository, deps Deps) *Service {
 	return &Service{
-		Auth:          NewAuthService(repo),
-		Products:      NewProductsService(repo),
-		Orders:        NewOrdersService(repo),
-		ProductsMedia: NewProductsMediaService(repo, s3, bucket),
+		Auth:          NewAuthService(repo, deps.SigningKeys, deps.Mailer, deps.AuthLinks, deps.TwoFactor, deps.LoginLockout, deps.OAuth),
+		Products:      NewProductsService(repo, deps.Delivery),
+		Orders:        NewOrdersService(repo, deps.Payments, deps.Delivery, deps.PaymentTTL, deps.ReservationTTL),
+		Payments:      NewPaymentsService(repo, deps.Payments, deps.PaymentReturnURL, deps.PaymentTTL),
+		Delivery:      NewDeliveryService(repo, deps.Delivery),
+		Idempotency:   NewIdempotencyService(repo, deps.IdempotencyTTL),
+		ShopPoints:    NewShopPointsService(repo),
+		PromoCodes:    NewPromoCodesService(repo),
+		ProductsMedia: NewProductsMediaService(repo, deps.S3, deps.Bucket),
 		Reviews:       NewReviewsService(repo),
+		Notifications: NewNotificationsService(repo, deps.Mailer, deps.NotificationTemplates, deps.NotificationOrdersURL, deps.NotificationMaxAttempts),
+		Addresses:     NewAddressesService(repo),
+		RateLimits:    NewRateLimitsService(deps.RateLimitStore, deps.RateLimitGroups),
+		GuestCarts:    NewGuestCartsService(repo, deps.SigningKeys, deps.GuestCartTTL),
 	}
 }
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxUserAgentLength = 512
+	maxIPLength        = 64
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, all sessions of this login were revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+// issueTokens signs a new token pair and stores the refresh token in the
+// family, which is created on sign in and kept across refreshes. Once 2FA is
+// enabled every sign in passes it, so the mfa claim follows the user's state.
+func (s *AuthService) issueTokens(userId int, familyId string, userAgent string, ip string) (string, string, error) {
+	user, err := s.repo.Auth.GetUser(userId)
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	accessClaims := jwt.MapClaims{
+		"exp":  now.Add(accessTTL).Unix(),
+		"id":   userId,
+		"typ":  accessTokenType,
+		"role": user.Role,
+		"ver":  user.TokenVersion,
+		"mfa":  user.TwoFactorEnabledAt != nil,
+	}
+	refreshClaims := jwt.MapClaims{
+		"exp": now.Add(refreshTTL).Unix(),
+		"id":  userId,
+		"typ": refreshTokenType,
+		"jti": uuid.NewString(),
+	}
+	access, err := s.NewToken(accessClaims)
+	if err != nil {
+		return "", "", err
+	}
+	refresh, err := s.NewToken(refreshClaims)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = s.repo.RefreshTokens.CreateRefreshToken(model.RefreshToken{
+		UserID:    userId,
+		TokenHash: hashToken(refresh),
+		FamilyID:  familyId,
+		UserAgent: truncate(userAgent, maxUserAgentLength),
+		IP:        truncate(ip, maxIPLength),
+		ExpiresAt: now.Add(refreshTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// useRefreshToken marks a stored refresh token as used. Presenting a token
+// that was already used or revoked means it leaked, so the whole family is
+// revoked and the legitimate owner has to sign in again.
+func (s *AuthService) useRefreshToken(refreshToken string) (model.RefreshToken, error) {
+	token, err := s.getRefreshToken(refreshToken)
+	if err != nil {
+		return model.RefreshToken{}, err
+	}
+	if token.UsedAt == nil && token.RevokedAt == nil {
+		used, err := s.repo.RefreshTokens.MarkRefreshTokenUsed(token.ID)
+		if err != nil {
+			return model.RefreshToken{}, err
+		}
+		if used {
+			return token, nil
+		}
+	}
+	if err := s.repo.RefreshTokens.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+		return model.RefreshToken{}, err
+	}
+	logrus.Warnf("Refresh token reuse detected for user %d, session family %s revoked", token.UserID, token.FamilyID)
+	return model.RefreshToken{}, ErrRefreshTokenReused
+}
+
+func (s *AuthService) getRefreshToken(refreshToken string) (model.RefreshToken, error) {
+	if _, err := s.parseClaims(refreshToken, refreshTokenType); err != nil {
+		return model.RefreshToken{}, ErrInvalidRefreshToken
+	}
+	token, err := s.repo.RefreshTokens.GetRefreshTokenByHash(hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.RefreshToken{}, ErrInvalidRefreshToken
+		}
+		return model.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+func (s *AuthService) Logout(refreshToken string) error {
+	token, err := s.getRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	return s.repo.RefreshTokens.RevokeRefreshTokenFamily(token.FamilyID)
+}
+
+func (s *AuthService) LogoutAll(userId int) error {
+	if err := s.repo.RefreshTokens.RevokeUserRefreshTokens(userId); err != nil {
+		return err
+	}
+	defer s.tokenVersions.invalidate(userId)
+	return s.repo.Auth.BumpTokenVersion(userId)
+}
+
+func (s *AuthService) GetSessions(userId int) ([]model.Session, error) {
+	return s.repo.RefreshTokens.GetActiveSessions(userId)
+}
+
+func (s *AuthService) RevokeSession(userId int, sessionId int) error {
+	err := s.repo.RefreshTokens.RevokeSession(userId, sessionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+func (s *AuthService) DeleteExpiredRefreshTokens(ctx context.Context) error {
+	deleted, err := s.repo.RefreshTokens.DeleteExpiredRefreshTokens(time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logrus.Infof("Deleted %d expired refresh tokens", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+var (
+	ErrShopPointNotFound = errors.New("shop point not found")
+	ErrShopPointInactive = errors.New("shop point is not active")
+)
+
+type ShopPointsService struct {
+	repo *repository.Repository
+}
+
+func NewShopPointsService(repo *repository.Repository) *ShopPointsService {
+	return &ShopPointsService{repo: repo}
+}
+
+func (s *ShopPointsService) CreateShopPoint(shopPoint model.ShopPoint) (int, error) {
+	return s.repo.ShopPoints.CreateShopPoint(shopPoint)
+}
+
+func (s *ShopPointsService) GetShopPoints(includeInactive bool) ([]model.ShopPoint, error) {
+	return s.repo.ShopPoints.GetShopPoints(!includeInactive)
+}
+
+func (s *ShopPointsService) GetShopPoint(shopPointId int) (model.ShopPoint, error) {
+	shopPoint, err := s.repo.ShopPoints.GetShopPoint(shopPointId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ShopPoint{}, ErrShopPointNotFound
+	}
+	return shopPoint, err
+}
+
+func (s *ShopPointsService) UpdateShopPoint(shopPointId int, shopPoint model.ShopPoint) error {
+	if _, err := s.GetShopPoint(shopPointId); err != nil {
+		return err
+	}
+	return s.repo.ShopPoints.UpdateShopPoint(shopPointId, shopPoint)
+}
+
+func (s *ShopPointsService) DeleteShopPoint(shopPointId int) error {
+	return s.repo.ShopPoints.DeleteShopPoint(shopPointId)
+}
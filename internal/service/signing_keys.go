@@ -0,0 +1,149 @@
+package service
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	HS256Algorithm = "HS256"
+	RS256Algorithm = "RS256"
+
+	minSecretLength = 32
+)
+
+var (
+	ErrNoSigningKeys      = errors.New("no signing keys configured")
+	ErrUnknownSigningKey  = errors.New("token is signed with unknown key")
+	ErrInvalidTokenMethod = errors.New("token signing method does not match its key")
+)
+
+type SigningKeyConfig struct {
+	ID            string
+	Algorithm     string
+	Secret        string
+	PrivateKeyPEM []byte
+}
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet holds every key tokens may be signed with. The first configured key
+// signs new tokens, the rest are kept so tokens issued before a rotation stay
+// valid until they expire.
+type KeySet struct {
+	current *signingKey
+	keys    map[string]*signingKey
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewKeySet(configs []SigningKeyConfig) (*KeySet, error) {
+	if len(configs) == 0 {
+		return nil, ErrNoSigningKeys
+	}
+	keySet := &KeySet{keys: make(map[string]*signingKey, len(configs))}
+	for _, config := range configs {
+		if config.ID == "" {
+			return nil, errors.New("signing key id is empty")
+		}
+		if _, ok := keySet.keys[config.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key id %s", config.ID)
+		}
+		key, err := newSigningKey(config)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", config.ID, err)
+		}
+		keySet.keys[config.ID] = key
+		if keySet.current == nil {
+			keySet.current = key
+		}
+	}
+	return keySet, nil
+}
+
+func newSigningKey(config SigningKeyConfig) (*signingKey, error) {
+	switch config.Algorithm {
+	case HS256Algorithm, "":
+		if len(config.Secret) < minSecretLength {
+			return nil, fmt.Errorf("secret must be at least %d bytes", minSecretLength)
+		}
+		return &signingKey{
+			id:        config.ID,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(config.Secret),
+			verifyKey: []byte(config.Secret),
+		}, nil
+	case RS256Algorithm:
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(config.PrivateKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		return &signingKey{
+			id:        config.ID,
+			method:    jwt.SigningMethodRS256,
+			signKey:   privateKey,
+			verifyKey: &privateKey.PublicKey,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", config.Algorithm)
+	}
+}
+
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.current.method, claims)
+	token.Header["kid"] = k.current.id
+	return token.SignedString(k.current.signKey)
+}
+
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidTokenMethod
+	}
+	return key.verifyKey, nil
+}
+
+// JWKS publishes public keys only, shared HMAC secrets never leave the service.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0)}
+	for _, key := range k.keys {
+		publicKey, ok := key.verifyKey.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, JWK{
+			KeyType:   "RSA",
+			KeyID:     key.id,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+			N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		})
+	}
+	return jwks
+}
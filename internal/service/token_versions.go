@@ -0,0 +1,52 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+const tokenVersionCacheTTL = 30 * time.Second
+
+type tokenVersionEntry struct {
+	version   int
+	fetchedAt time.Time
+}
+
+// tokenVersionCache keeps users' token versions for a short time so access
+// tokens can be checked without a query per request. Local changes invalidate
+// the entry right away, changes made by other instances are seen after the TTL.
+type tokenVersionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]tokenVersionEntry
+}
+
+func newTokenVersionCache(ttl time.Duration) *tokenVersionCache {
+	return &tokenVersionCache{
+		ttl:     ttl,
+		entries: make(map[int]tokenVersionEntry),
+	}
+}
+
+func (c *tokenVersionCache) get(userId int, load func(userId int) (int, error)) (int, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[userId]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.version, nil
+	}
+	version, err := load(userId)
+	if err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	c.entries[userId] = tokenVersionEntry{version: version, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return version, nil
+}
+
+func (c *tokenVersionCache) invalidate(userId int) {
+	c.mu.Lock()
+	delete(c.entries, userId)
+	c.mu.Unlock()
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 Appendix B test vectors.
+const rfc6238Secret = "12345678901234567890"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; a 6 digit code is their last six digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	secret := totpEncoding.EncodeToString([]byte(rfc6238Secret))
+	for _, test := range tests {
+		want := test.code[len(test.code)-totpDigits:]
+		if code := totpCode([]byte(rfc6238Secret), test.unix/totpPeriod); code != want {
+			t.Errorf("totpCode at %d = %s, want %s", test.unix, code, want)
+		}
+		step, ok := validateTOTP(secret, want, time.Unix(test.unix, 0))
+		if !ok || step != test.unix/totpPeriod {
+			t.Errorf("validateTOTP(%s) at %d = %d, %t, want %d, true", want, test.unix, step, ok, test.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	key := []byte(rfc6238Secret)
+	secret := totpEncoding.EncodeToString(key)
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	for offset := int64(-3); offset <= 3; offset++ {
+		step, ok := validateTOTP(secret, totpCode(key, current+offset), now)
+		want := offset >= -totpSkew && offset <= totpSkew
+		if ok != want {
+			t.Errorf("code %+d steps away accepted = %t, want %t", offset, ok, want)
+		}
+		if ok && step != current+offset {
+			t.Errorf("code %+d steps away matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+	if _, ok := validateTOTP(secret, "12345", now); ok {
+		t.Error("short code accepted")
+	}
+	if _, ok := validateTOTP("not base32!", totpCode(key, current), now); ok {
+		t.Error("code accepted for an invalid secret")
+	}
+}
+
+// fakeTwoFactorRepo keeps one user's 2FA state the way TwoFactorPostgres
+// does: TOTP steps must move forward and recovery codes are deleted on use.
+type fakeTwoFactorRepo struct {
+	repository.TwoFactor
+	twoFactor     model.TwoFactor
+	recoveryCodes map[string]bool
+}
+
+func (r *fakeTwoFactorRepo) GetTwoFactor(userId int) (model.TwoFactor, error) {
+	return r.twoFactor, nil
+}
+
+func (r *fakeTwoFactorRepo) UseTOTPStep(userId int, step int64) (bool, error) {
+	if r.twoFactor.LastStep != nil && *r.twoFactor.LastStep >= step {
+		return false, nil
+	}
+	r.twoFactor.LastStep = &step
+	return true, nil
+}
+
+func (r *fakeTwoFactorRepo) UseRecoveryCode(userId int, codeHash string) (bool, error) {
+	if !r.recoveryCodes[codeHash] {
+		return false, nil
+	}
+	delete(r.recoveryCodes, codeHash)
+	return true, nil
+}
+
+func newTwoFactorTestService(t *testing.T) (*AuthService, []string, []byte) {
+	t.Helper()
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabledAt := time.Now()
+	repo := &fakeTwoFactorRepo{
+		twoFactor:     model.TwoFactor{Secret: &secret, EnabledAt: &enabledAt},
+		recoveryCodes: make(map[string]bool),
+	}
+	for _, hash := range hashes {
+		repo.recoveryCodes[hash] = true
+	}
+	return &AuthService{repo: &repository.Repository{TwoFactor: repo}}, codes, key
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	s, codes, _ := newTwoFactorTestService(t)
+	if len(codes) != recoveryCodesCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodesCount)
+	}
+	// Users may type the code without the dash and in upper case.
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+	if err := s.checkTwoFactorCode(1, typed); err != nil {
+		t.Fatalf("first use of a recovery code: %s", err)
+	}
+	if err := s.checkTwoFactorCode(1, codes[0]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("second use of a recovery code = %v, want %v", err, ErrInvalidTwoFactorCode)
+	}
+	if err := s.checkTwoFactorCode(1, codes[1]); err != nil {
+		t.Fatalf("another recovery code after the first was used: %s", err)
+	}
+	if err := s.checkTwoFactorCode(1, "aaaaa-bbbbb"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("unknown recovery code = %v, want %v", err, ErrInvalidTwoFactorCode)
+	}
+}
+
+func TestTOTPCodeIsSingleUse(t *testing.T) {
+	s, _, key := newTwoFactorTestService(t)
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+	if err := s.checkTwoFactorCode(1, code); err != nil {
+		t.Fatalf("first use of a TOTP code: %s", err)
+	}
+	if err := s.checkTwoFactorCode(1, code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("replayed TOTP code = %v, want %v", err, ErrInvalidTwoFactorCode)
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	twoFactorTokenType    = "two_factor"
+	twoFactorChallengeTTL = 5 * time.Minute
+
+	recoveryCodesCount  = 10
+	recoveryCodeLength  = 10
+	recoveryCodeGroup   = 5
+	recoveryCodeCharset = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+var (
+	ErrTwoFactorEnabled          = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorSetupMissing     = errors.New("two-factor setup was not started")
+	ErrTwoFactorRequired         = errors.New("two-factor authentication is required for this role")
+	ErrInvalidTwoFactorCode      = errors.New("invalid two-factor code")
+	ErrInvalidTwoFactorChallenge = errors.New("two-factor challenge is invalid or has expired")
+)
+
+// TwoFactorPolicy names the TOTP issuer shown in authenticator apps and the
+// roles that can't use their permissions without a second factor.
+type TwoFactorPolicy struct {
+	Issuer        string
+	RequiredRoles []string
+}
+
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// SignInResult holds either a token pair or, when the account has 2FA
+// enabled, a challenge token to exchange for one at SignInTwoFactor.
+type SignInResult struct {
+	UserID         int
+	AccessToken    string
+	RefreshToken   string
+	ChallengeToken string
+}
+
+func (s *AuthService) TwoFactorRequired(role string) bool {
+	for _, requiredRole := range s.twoFactor.RequiredRoles {
+		if role == requiredRole {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupTwoFactor stores a new secret that starts working only after the user
+// confirms it with a code from the authenticator app.
+func (s *AuthService) SetupTwoFactor(userId int, password string) (TwoFactorSetup, error) {
+	user, err := s.checkUserPassword(userId, password)
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+	if user.TwoFactorEnabledAt != nil {
+		return TwoFactorSetup{}, ErrTwoFactorEnabled
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+	if err := s.repo.TwoFactor.SetTOTPSecret(userId, secret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return TwoFactorSetup{}, ErrTwoFactorEnabled
+		}
+		return TwoFactorSetup{}, err
+	}
+	return TwoFactorSetup{
+		Secret: secret,
+		URI:    totpURI(s.twoFactor.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables 2FA and returns recovery codes, which are shown
+// only once. Other sessions are signed out because they were opened without
+// the second factor.
+func (s *AuthService) ConfirmTwoFactor(userId int, code string, userAgent string, ip string) ([]string, string, string, error) {
+	twoFactor, err := s.repo.TwoFactor.GetTwoFactor(userId)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if twoFactor.EnabledAt != nil {
+		return nil, "", "", ErrTwoFactorEnabled
+	}
+	if twoFactor.Secret == nil {
+		return nil, "", "", ErrTwoFactorSetupMissing
+	}
+	step, ok := validateTOTP(*twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, "", "", ErrInvalidTwoFactorCode
+	}
+	recoveryCodes, recoveryCodeHashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, "", "", err
+	}
+	if err := s.repo.TwoFactor.EnableTwoFactor(userId, step, recoveryCodeHashes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, "", "", ErrTwoFactorEnabled
+		}
+		return nil, "", "", err
+	}
+	if err := s.LogoutAll(userId); err != nil {
+		return nil, "", "", err
+	}
+	access, refresh, err := s.issueTokens(userId, uuid.NewString(), userAgent, ip)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return recoveryCodes, access, refresh, nil
+}
+
+func (s *AuthService) DisableTwoFactor(userId int, password string, code string) error {
+	user, err := s.checkUserPassword(userId, password)
+	if err != nil {
+		return err
+	}
+	if s.TwoFactorRequired(user.Role) {
+		return ErrTwoFactorRequired
+	}
+	if err := s.checkTwoFactorCode(userId, code); err != nil {
+		return err
+	}
+	return s.repo.TwoFactor.DisableTwoFactor(userId)
+}
+
+func (s *AuthService) RegenerateRecoveryCodes(userId int, code string) ([]string, error) {
+	if err := s.checkTwoFactorCode(userId, code); err != nil {
+		return nil, err
+	}
+	recoveryCodes, recoveryCodeHashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.TwoFactor.ReplaceRecoveryCodes(userId, recoveryCodeHashes); err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// SignInTwoFactor finishes a sign in started with a password. The challenge
+// token is spent only by a correct code, so a typo doesn't mean entering the
+// password again; wrong codes count towards the sign in lockout instead.
+func (s *AuthService) SignInTwoFactor(challengeToken string, code string, userAgent string, ip string) (SignInResult, error) {
+	if _, err := s.parseClaims(challengeToken, twoFactorTokenType); err != nil {
+		return SignInResult{}, ErrInvalidTwoFactorChallenge
+	}
+	userToken, err := s.repo.UserTokens.GetUserTokenByHash(hashToken(challengeToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SignInResult{}, ErrInvalidTwoFactorChallenge
+		}
+		return SignInResult{}, err
+	}
+	if userToken.Purpose != twoFactorTokenType || userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return SignInResult{}, ErrInvalidTwoFactorChallenge
+	}
+	user, err := s.repo.Auth.GetUser(userToken.UserID)
+	if err != nil {
+		return SignInResult{}, err
+	}
+	account := loginAccount(user.Email)
+	if err := s.checkLoginLock(account); err != nil {
+		return SignInResult{}, err
+	}
+	if err := s.checkTwoFactorCode(userToken.UserID, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.registerLoginFailure(account)
+		}
+		return SignInResult{}, err
+	}
+	used, err := s.repo.UserTokens.UseUserToken(userToken.ID)
+	if err != nil {
+		return SignInResult{}, err
+	}
+	if !used {
+		return SignInResult{}, ErrInvalidTwoFactorChallenge
+	}
+	s.resetLoginFailures(account)
+	access, refresh, err := s.issueTokens(userToken.UserID, uuid.NewString(), userAgent, ip)
+	if err != nil {
+		return SignInResult{}, err
+	}
+	return SignInResult{UserID: userToken.UserID, AccessToken: access, RefreshToken: refresh}, nil
+}
+
+// checkTwoFactorCode accepts either a TOTP code or an unused recovery code.
+func (s *AuthService) checkTwoFactorCode(userId int, code string) error {
+	twoFactor, err := s.repo.TwoFactor.GetTwoFactor(userId)
+	if err != nil {
+		return err
+	}
+	if twoFactor.EnabledAt == nil || twoFactor.Secret == nil {
+		return ErrTwoFactorNotEnabled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		step, ok := validateTOTP(*twoFactor.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		used, err := s.repo.TwoFactor.UseTOTPStep(userId, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+	used, err := s.repo.TwoFactor.UseRecoveryCode(userId, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	random := make([]byte, recoveryCodeLength)
+	for len(codes) < recoveryCodesCount {
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, err
+		}
+		var code strings.Builder
+		for i, b := range random {
+			if i == recoveryCodeGroup {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeCharset[int(b)%len(recoveryCodeCharset)])
+		}
+		codes = append(codes, code.String())
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code.String())))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
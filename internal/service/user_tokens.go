@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	emailVerificationTokenType = "email_verification"
+	passwordResetTokenType     = "password_reset"
+	reauthTokenType            = "reauth"
+
+	emailVerificationTTL = 24 * time.Hour
+	passwordResetTTL     = time.Hour
+	reauthTTL            = 15 * time.Minute
+)
+
+var ErrInvalidUserToken = errors.New("link is invalid or has expired")
+
+type AuthLinks struct {
+	VerifyEmailURL        string
+	ResetPasswordURL      string
+	ConfirmEmailChangeURL string
+	ReauthURL             string
+}
+
+// newUserToken signs a single-use token for an emailed link. Only its hash is
+// stored, and earlier unused tokens with the same purpose stop working.
+func (s *AuthService) newUserToken(userId int, purpose string, ttl time.Duration) (string, error) {
+	expiresAt := time.Now().Add(ttl)
+	token, err := s.NewToken(jwt.MapClaims{
+		"exp": expiresAt.Unix(),
+		"id":  userId,
+		"typ": purpose,
+		"jti": uuid.NewString(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.UserTokens.InvalidateUserTokens(userId, purpose); err != nil {
+		return "", err
+	}
+	_, err = s.repo.UserTokens.CreateUserToken(model.UserToken{
+		UserID:    userId,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *AuthService) useUserToken(token string, purpose string) (model.UserToken, error) {
+	if _, err := s.parseClaims(token, purpose); err != nil {
+		return model.UserToken{}, ErrInvalidUserToken
+	}
+	userToken, err := s.repo.UserTokens.GetUserTokenByHash(hashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.UserToken{}, ErrInvalidUserToken
+		}
+		return model.UserToken{}, err
+	}
+	if userToken.Purpose != purpose {
+		return model.UserToken{}, ErrInvalidUserToken
+	}
+	used, err := s.repo.UserTokens.UseUserToken(userToken.ID)
+	if err != nil {
+		return model.UserToken{}, err
+	}
+	if !used {
+		return model.UserToken{}, ErrInvalidUserToken
+	}
+	return userToken, nil
+}
+
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user model.User) error {
+	token, err := s.newUserToken(user.ID, emailVerificationTokenType, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, Email{
+		To:      user.Email,
+		Subject: "Подтверждение почты",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить почту, перейдите по ссылке:\n%s\n\nСсылка действует 24 часа.\n",
+			user.Name, linkWithToken(s.links.VerifyEmailURL, token)),
+	})
+}
+
+func (s *AuthService) VerifyEmail(token string) error {
+	userToken, err := s.useUserToken(token, emailVerificationTokenType)
+	if err != nil {
+		return err
+	}
+	return s.repo.Auth.MarkEmailVerified(userToken.UserID)
+}
+
+// ForgotPassword emails a reset link. Unknown emails are not reported, so the
+// endpoint can't be used to find out who has an account.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.repo.Auth.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	token, err := s.newUserToken(user.ID, passwordResetTokenType, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, Email{
+		To:      user.Email,
+		Subject: "Восстановление пароля",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы задать новый пароль, перейдите по ссылке:\n%s\n\nСсылка действует 1 час. Если вы не запрашивали восстановление, просто проигнорируйте это письмо.\n",
+			user.Name, linkWithToken(s.links.ResetPasswordURL, token)),
+	})
+}
+
+// ResetPassword sets a new password and signs the user out everywhere. The
+// link came to the user's inbox, so it also confirms the email.
+func (s *AuthService) ResetPassword(token string, password string) error {
+	userToken, err := s.useUserToken(token, passwordResetTokenType)
+	if err != nil {
+		return err
+	}
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Auth.UpdatePasswordHash(userToken.UserID, passwordHash); err != nil {
+		return err
+	}
+	if err := s.repo.Auth.MarkEmailVerified(userToken.UserID); err != nil {
+		return err
+	}
+	return s.LogoutAll(userToken.UserID)
+}
+
+func (s *AuthService) DeleteExpiredUserTokens(ctx context.Context) error {
+	deleted, err := s.repo.UserTokens.DeleteExpiredUserTokens(time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logrus.Infof("Deleted %d expired user tokens", deleted)
+	}
+	return nil
+}
+
+func linkWithToken(baseURL string, token string) string {
+	link, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL + "?token=" + url.QueryEscape(token)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lavatee/dresscode_backend/internal/model"
+	"github.com/lavatee/dresscode_backend/internal/repository"
+)
+
+// fakeAuthRepo keeps users, emailed tokens and refresh token revocations in
+// memory, behaving like the Postgres repositories for the calls the emailed
+// link flows make.
+type fakeAuthRepo struct {
+	repository.Auth
+	repository.UserTokens
+	repository.RefreshTokens
+	users          map[int]*model.User
+	tokens         map[int]*model.UserToken
+	revokedRefresh map[int]bool
+}
+
+func newFakeAuthRepo(users ...model.User) *fakeAuthRepo {
+	repo := &fakeAuthRepo{
+		users:          make(map[int]*model.User),
+		tokens:         make(map[int]*model.UserToken),
+		revokedRefresh: make(map[int]bool),
+	}
+	for i := range users {
+		repo.users[users[i].ID] = &users[i]
+	}
+	return repo
+}
+
+func (r *fakeAuthRepo) GetUser(userId int) (model.User, error) {
+	user, ok := r.users[userId]
+	if !ok {
+		return model.User{}, sql.ErrNoRows
+	}
+	return *user, nil
+}
+
+func (r *fakeAuthRepo) GetUserByEmail(email string) (model.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return *user, nil
+		}
+	}
+	return model.User{}, sql.ErrNoRows
+}
+
+func (r *fakeAuthRepo) UpdatePasswordHash(userId int, passwordHash string) error {
+	r.users[userId].Password = passwordHash
+	return nil
+}
+
+func (r *fakeAuthRepo) MarkEmailVerified(userId int) error {
+	if r.users[userId].EmailVerifiedAt == nil {
+		now := time.Now()
+		r.users[userId].EmailVerifiedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeAuthRepo) BumpTokenVersion(userId int) error {
+	r.users[userId].TokenVersion++
+	return nil
+}
+
+func (r *fakeAuthRepo) RevokeUserRefreshTokens(userId int) error {
+	r.revokedRefresh[userId] = true
+	return nil
+}
+
+func (r *fakeAuthRepo) CreateUserToken(token model.UserToken) (int, error) {
+	token.ID = len(r.tokens) + 1
+	r.tokens[token.ID] = &token
+	return token.ID, nil
+}
+
+func (r *fakeAuthRepo) GetUserTokenByHash(tokenHash string) (model.UserToken, error) {
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return *token, nil
+		}
+	}
+	return model.UserToken{}, sql.ErrNoRows
+}
+
+func (r *fakeAuthRepo) UseUserToken(tokenId int) (bool, error) {
+	token := r.tokens[tokenId]
+	if token.UsedAt != nil || !token.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+func (r *fakeAuthRepo) InvalidateUserTokens(userId int, purpose string) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userId && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+func newUserTokensTestService(t *testing.T, users ...model.User) (*AuthService, *fakeAuthRepo, *OutboxMailer) {
+	t.Helper()
+	keys, err := NewKeySet([]SigningKeyConfig{{ID: "test", Algorithm: HS256Algorithm, Secret: "0123456789abcdef0123456789abcdef"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newFakeAuthRepo(users...)
+	mailer := NewOutboxMailer("Dress Code <no-reply@dresscode.local>", "")
+	links := AuthLinks{
+		VerifyEmailURL:   "http://localhost:3000/verify-email",
+		ResetPasswordURL: "http://localhost:3000/reset-password",
+	}
+	s := NewAuthService(&repository.Repository{Auth: repo, UserTokens: repo, RefreshTokens: repo}, keys, mailer, links, TwoFactorPolicy{}, LoginLockoutPolicy{}, OAuthConfig{})
+	return s, repo, mailer
+}
+
+var emailLinkPattern = regexp.MustCompile(`http://\S+`)
+
+// lastEmailToken returns the token from the link in the latest email sent.
+func lastEmailToken(t *testing.T, mailer *OutboxMailer, to string) string {
+	t.Helper()
+	emails := mailer.Emails()
+	if len(emails) == 0 {
+		t.Fatal("no email sent")
+	}
+	email := emails[len(emails)-1]
+	if email.To != to {
+		t.Fatalf("email sent to %s, want %s", email.To, to)
+	}
+	link, err := url.Parse(emailLinkPattern.FindString(email.Text))
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("no link with a token in the email:\n%s", email.Text)
+	}
+	return link.Query().Get("token")
+}
+
+func TestVerifyEmailTokenIsSingleUse(t *testing.T) {
+	s, repo, mailer := newUserTokensTestService(t, model.User{ID: 1, Name: "Anna", Email: "anna@example.com"})
+	if err := s.ResendVerificationEmail(context.Background(), 1); err != nil {
+		t.Fatalf("ResendVerificationEmail: %s", err)
+	}
+	token := lastEmailToken(t, mailer, "anna@example.com")
+
+	if err := s.VerifyEmail(token); err != nil {
+		t.Fatalf("VerifyEmail: %s", err)
+	}
+	if repo.users[1].EmailVerifiedAt == nil {
+		t.Fatal("email not marked verified")
+	}
+	if err := s.VerifyEmail(token); !errors.Is(err, ErrInvalidUserToken) {
+		t.Fatalf("reused verification token = %v, want %v", err, ErrInvalidUserToken)
+	}
+	if err := s.ResendVerificationEmail(context.Background(), 1); !errors.Is(err, ErrEmailVerified) {
+		t.Fatalf("resend after verification = %v, want %v", err, ErrEmailVerified)
+	}
+}
+
+func TestResendVerificationEmailInvalidatesEarlierLink(t *testing.T) {
+	s, _, mailer := newUserTokensTestService(t, model.User{ID: 1, Name: "Anna", Email: "anna@example.com"})
+	if err := s.ResendVerificationEmail(context.Background(), 1); err != nil {
+		t.Fatalf("ResendVerificationEmail: %s", err)
+	}
+	first := lastEmailToken(t, mailer, "anna@example.com")
+	if err := s.ResendVerificationEmail(context.Background(), 1); err != nil {
+		t.Fatalf("ResendVerificationEmail: %s", err)
+	}
+	second := lastEmailToken(t, mailer, "anna@example.com")
+
+	if err := s.VerifyEmail(first); !errors.Is(err, ErrInvalidUserToken) {
+		t.Fatalf("earlier verification token = %v, want %v", err, ErrInvalidUserToken)
+	}
+	if err := s.VerifyEmail(second); err != nil {
+		t.Fatalf("latest verification token: %s", err)
+	}
+}
+
+func TestResetPasswordTokenIsSingleUse(t *testing.T) {
+	s, repo, mailer := newUserTokensTestService(t, model.User{ID: 1, Name: "Anna", Email: "anna@example.com"})
+	if err := s.ForgotPassword(context.Background(), "anna@example.com"); err != nil {
+		t.Fatalf("ForgotPassword: %s", err)
+	}
+	token := lastEmailToken(t, mailer, "anna@example.com")
+
+	if err := s.ResetPassword(token, "new-password"); err != nil {
+		t.Fatalf("ResetPassword: %s", err)
+	}
+	if ok, _ := checkPassword(repo.users[1].Password, "new-password"); !ok {
+		t.Fatal("password not changed")
+	}
+	if repo.users[1].EmailVerifiedAt == nil {
+		t.Error("reset link didn't confirm the email")
+	}
+	if !repo.revokedRefresh[1] || repo.users[1].TokenVersion != 1 {
+		t.Error("sessions not signed out after the reset")
+	}
+	if err := s.ResetPassword(token, "another-password"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Fatalf("reused reset token = %v, want %v", err, ErrInvalidUserToken)
+	}
+	if ok, _ := checkPassword(repo.users[1].Password, "new-password"); !ok {
+		t.Fatal("reused reset token changed the password")
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	s, _, mailer := newUserTokensTestService(t, model.User{ID: 1, Name: "Anna", Email: "anna@example.com"})
+	if err := s.ForgotPassword(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("ForgotPassword for an unknown email: %s", err)
+	}
+	if emails := mailer.Emails(); len(emails) != 0 {
+		t.Fatalf("sent %d emails for an unknown address", len(emails))
+	}
+}
+
+func TestUserTokenRejectedWhenExpiredOrForAnotherPurpose(t *testing.T) {
+	s, _, _ := newUserTokensTestService(t, model.User{ID: 1, Name: "Anna", Email: "anna@example.com"})
+	expired, err := s.newUserToken(1, passwordResetTokenType, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(expired, "new-password"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Fatalf("expired reset token = %v, want %v", err, ErrInvalidUserToken)
+	}
+	verification, err := s.newUserToken(1, emailVerificationTokenType, emailVerificationTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPassword(verification, "new-password"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Fatalf("verification token used for a reset = %v, want %v", err, ErrInvalidUserToken)
+	}
+	if err := s.VerifyEmail(verification); err != nil {
+		t.Fatalf("verification token after a failed reset: %s", err)
+	}
+}
+
+func TestOutboxMailerKeepsLatestEmails(t *testing.T) {
+	mailer := NewOutboxMailer("Dress Code <no-reply@dresscode.local>", "")
+	for i := 0; i < outboxKeep+5; i++ {
+		if err := mailer.Send(context.Background(), Email{To: "anna@example.com", Subject: strconv.Itoa(i), Text: "test"}); err != nil {
+			t.Fatalf("Send: %s", err)
+		}
+	}
+	emails := mailer.Emails()
+	if len(emails) != outboxKeep {
+		t.Fatalf("outbox keeps %d emails, want %d", len(emails), outboxKeep)
+	}
+	if emails[0].Subject != "5" || emails[len(emails)-1].Subject != strconv.Itoa(outboxKeep+4) {
+		t.Fatalf("outbox keeps emails %s to %s, want the latest ones", emails[0].Subject, emails[len(emails)-1].Subject)
+	}
+}
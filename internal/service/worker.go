@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func RunWorker(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Infof("Worker %s stopped", name)
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				logrus.Errorf("worker %s error: %s", name, err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS product_prices (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    price INT NOT NULL CHECK (price > 0),
+    compare_at_price INT CHECK (compare_at_price > 0),
+    effective_from TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    effective_to TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (effective_to IS NULL OR effective_from < effective_to)
+);
+
+CREATE INDEX IF NOT EXISTS product_prices_product_id_effective_from_idx ON product_prices (product_id, effective_from DESC);
+
+ALTER TABLE product_prices ADD FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
@@ -0,0 +1,24 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(2) NOT NULL DEFAULT 'ru';
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    order_id INT,
+    event VARCHAR(32) NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    recipient VARCHAR(255),
+    subject VARCHAR(255),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS notifications_pending_idx ON notifications (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS notifications_order_id_idx ON notifications (order_id);
+
+ALTER TABLE notifications ADD FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE notifications ADD FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
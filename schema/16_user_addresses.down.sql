@@ -0,0 +1,4 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS recipient_phone;
+ALTER TABLE orders DROP COLUMN IF EXISTS recipient_name;
+ALTER TABLE orders DROP COLUMN IF EXISTS address_id;
+DROP TABLE IF EXISTS user_addresses;
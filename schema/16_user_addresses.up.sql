@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS user_addresses (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    recipient_name VARCHAR(255) NOT NULL,
+    phone VARCHAR(32) NOT NULL,
+    city VARCHAR(255) NOT NULL,
+    street VARCHAR(255) NOT NULL,
+    postal_index INT NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_addresses_user_id_idx ON user_addresses (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS user_addresses_default_idx ON user_addresses (user_id) WHERE is_default;
+
+ALTER TABLE user_addresses ADD FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS address_id INT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS recipient_name VARCHAR(255);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS recipient_phone VARCHAR(32);
+ALTER TABLE orders ADD FOREIGN KEY (address_id) REFERENCES user_addresses(id) ON DELETE SET NULL;
@@ -0,0 +1,14 @@
+DELETE FROM products_in_cart WHERE guest_cart_id IS NOT NULL;
+
+DROP INDEX IF EXISTS products_in_cart_user_line_idx;
+DELETE FROM products_in_cart a USING products_in_cart b
+WHERE a.user_id = b.user_id AND a.size = b.size AND a.id > b.id;
+ALTER TABLE products_in_cart DROP CONSTRAINT IF EXISTS products_in_cart_user_id_size_key;
+ALTER TABLE products_in_cart ADD CONSTRAINT products_in_cart_user_id_size_key UNIQUE (user_id, size);
+
+DROP INDEX IF EXISTS products_in_cart_guest_line_idx;
+ALTER TABLE products_in_cart DROP CONSTRAINT IF EXISTS products_in_cart_owner_check;
+ALTER TABLE products_in_cart DROP COLUMN IF EXISTS guest_cart_id;
+ALTER TABLE products_in_cart ALTER COLUMN user_id SET NOT NULL;
+
+DROP TABLE IF EXISTS guest_carts;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS guest_carts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS guest_carts_updated_at_idx ON guest_carts (updated_at);
+
+ALTER TABLE products_in_cart ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE products_in_cart ADD COLUMN IF NOT EXISTS guest_cart_id UUID;
+ALTER TABLE products_in_cart ADD FOREIGN KEY (guest_cart_id) REFERENCES guest_carts(id) ON DELETE CASCADE;
+ALTER TABLE products_in_cart ADD CONSTRAINT products_in_cart_owner_check CHECK ((user_id IS NULL) <> (guest_cart_id IS NULL));
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_in_cart_guest_line_idx ON products_in_cart (guest_cart_id, product_id, size) WHERE guest_cart_id IS NOT NULL;
+
+-- Merging a guest cart keeps a line per product and size, so the baseline
+-- UNIQUE (user_id, size) key is replaced by one on the whole line.
+ALTER TABLE products_in_cart DROP CONSTRAINT IF EXISTS products_in_cart_user_id_size_key;
+CREATE UNIQUE INDEX IF NOT EXISTS products_in_cart_user_line_idx ON products_in_cart (user_id, product_id, size) WHERE user_id IS NOT NULL;
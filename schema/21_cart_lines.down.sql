@@ -0,0 +1 @@
+-- The line key belongs to migration 20 and is restored by its down migration.
@@ -0,0 +1,5 @@
+-- Migration 20 now swaps the cart key itself; this is kept for databases that
+-- applied the earlier version of 20, which left UNIQUE (user_id, size) in place.
+ALTER TABLE products_in_cart DROP CONSTRAINT IF EXISTS products_in_cart_user_id_size_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_in_cart_user_line_idx ON products_in_cart (user_id, product_id, size) WHERE user_id IS NOT NULL;
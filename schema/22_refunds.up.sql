@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,
+    payment_id VARCHAR(255) NOT NULL,
+    amount INT NOT NULL CHECK (amount > 0),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('cancel', 'return', 'unfulfilled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    refunded_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS refunds_pending_idx ON refunds (created_at) WHERE refunded_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS refunds_unfulfilled_payment_idx ON refunds (payment_id) WHERE reason = 'unfulfilled';
+
+ALTER TABLE refunds ADD FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_id_idx ON order_status_history (order_id);
+
+ALTER TABLE order_status_history ADD FOREIGN KEY (order_id) REFERENCES orders(id);
+ALTER TABLE order_status_history ADD FOREIGN KEY (changed_by) REFERENCES users(id);
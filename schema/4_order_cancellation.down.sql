@@ -0,0 +1,5 @@
+ALTER TABLE ordered_products DROP CONSTRAINT IF EXISTS ordered_products_returned_amount_check;
+ALTER TABLE ordered_products DROP COLUMN IF EXISTS returned_amount;
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('pending', 'created', 'delivered_to_shop', 'issued', 'sent_to_customer', 'delivered_to_customer'));
@@ -0,0 +1,5 @@
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('pending', 'created', 'delivered_to_shop', 'issued', 'sent_to_customer', 'delivered_to_customer', 'cancelled', 'returned'));
+
+ALTER TABLE ordered_products ADD COLUMN IF NOT EXISTS returned_amount BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE ordered_products ADD CONSTRAINT ordered_products_returned_amount_check CHECK (returned_amount >= 0 AND returned_amount <= amount);
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS weight INT NOT NULL DEFAULT 500;
@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS shop_point_id;
+DROP TABLE IF EXISTS shop_points;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS shop_points (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    address VARCHAR(255) NOT NULL UNIQUE,
+    working_hours VARCHAR(255) NOT NULL DEFAULT '',
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS shop_point_id INT;
+ALTER TABLE orders ADD FOREIGN KEY (shop_point_id) REFERENCES shop_points(id);
+
+INSERT INTO shop_points (name, address, is_active)
+SELECT DISTINCT shop_point, shop_point, FALSE FROM orders WHERE shop_point IS NOT NULL AND shop_point <> ''
+ON CONFLICT (address) DO NOTHING;
+
+UPDATE orders o SET shop_point_id = sp.id FROM shop_points sp WHERE o.shop_point = sp.address;
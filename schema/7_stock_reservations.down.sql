@@ -0,0 +1,2 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS stock_committed;
+DROP TABLE IF EXISTS stock_reservations;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    product_id INT NOT NULL,
+    size VARCHAR(15) NOT NULL,
+    amount INT NOT NULL CHECK (amount > 0),
+    order_id INT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS stock_reservations_product_size_idx ON stock_reservations (product_id, size, expires_at);
+CREATE INDEX IF NOT EXISTS stock_reservations_order_id_idx ON stock_reservations (order_id);
+
+ALTER TABLE stock_reservations ADD FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE stock_reservations ADD FOREIGN KEY (product_id) REFERENCES products(id);
+ALTER TABLE stock_reservations ADD FOREIGN KEY (order_id) REFERENCES orders(id);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS stock_committed BOOLEAN NOT NULL DEFAULT TRUE;
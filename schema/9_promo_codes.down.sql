@@ -0,0 +1,6 @@
+ALTER TABLE ordered_products DROP COLUMN IF EXISTS discount_amount;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount_amount;
+ALTER TABLE orders DROP COLUMN IF EXISTS promo_code;
+ALTER TABLE orders DROP COLUMN IF EXISTS promo_code_id;
+DROP TABLE IF EXISTS promo_code_usages;
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(64) NOT NULL UNIQUE,
+    discount_type VARCHAR(16) NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    value INT NOT NULL CHECK (value > 0),
+    min_order_sum INT NOT NULL DEFAULT 0 CHECK (min_order_sum >= 0),
+    valid_from TIMESTAMPTZ,
+    valid_to TIMESTAMPTZ,
+    usage_limit INT CHECK (usage_limit > 0),
+    usage_limit_per_user INT CHECK (usage_limit_per_user > 0),
+    collection_id INT,
+    category VARCHAR(255),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (discount_type <> 'percent' OR value <= 100),
+    CHECK (valid_from IS NULL OR valid_to IS NULL OR valid_from < valid_to)
+);
+
+CREATE TABLE IF NOT EXISTS promo_code_usages (
+    id SERIAL PRIMARY KEY,
+    promo_code_id INT NOT NULL,
+    user_id INT NOT NULL,
+    order_id INT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS promo_code_usages_promo_code_user_idx ON promo_code_usages (promo_code_id, user_id);
+
+ALTER TABLE promo_codes ADD FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE;
+ALTER TABLE promo_code_usages ADD FOREIGN KEY (promo_code_id) REFERENCES promo_codes(id);
+ALTER TABLE promo_code_usages ADD FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE promo_code_usages ADD FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS promo_code_id INT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS promo_code VARCHAR(64);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_amount INT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD FOREIGN KEY (promo_code_id) REFERENCES promo_codes(id) ON DELETE SET NULL;
+
+ALTER TABLE ordered_products ADD COLUMN IF NOT EXISTS discount_amount INT NOT NULL DEFAULT 0;